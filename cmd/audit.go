@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/render"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit user [log-file]",
+	Short: "Lists every request made by an authenticated user",
+	Long: `
+Lists every request made by an authenticated user
+
+The user is matched against the user ID of HTTP authentication, or the
+identity reported by identd for requests without a user ID. Requests are
+listed in log order, and can be exported for an audit trail, e.g.
+  digio-task audit --output csv admin access.log > admin.csv
+
+The configured filter is applied, e.g. to audit a period or only failures.
+A summary of any omitted log lines is printed to stderr.
+`,
+	Args: cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			logReader = newArgLogReader(args[1])
+			viper.Set("log-file", argLogName(args[1]))
+		}
+
+		return RunAudit(logReader, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+}
+
+// RunAudit lists the requests of an authenticated user in the configured output format: table, json or csv,
+// and a summary of any omitted log lines to stderr.
+func RunAudit(logReader log.LogReader, user string) error {
+	logEntries, parseReport, err := loadLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return err
+	}
+
+	// lines which could not be parsed are missing from the audit trail
+	if err := render.RenderParseSummary(os.Stderr, parseReport); err != nil {
+		return err
+	}
+
+	renderer, err := render.NewRequestsRenderer(viper.GetString("output"), tableOptions())
+	if err != nil {
+		return err
+	}
+
+	meta := reportMetadata()
+	meta.User = user
+
+	return renderer.RenderRequests(os.Stdout, meta, log.UserRequests(logEntries, user))
+}
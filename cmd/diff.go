@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/render"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff --baseline old.log --current new.log",
+	Short: "Compares the analysis of a baseline log period with a current log period",
+	Long: `
+Compares the analysis of a baseline log period with a current log period
+
+Reports the change in requests, unique IP addresses, error rate and bandwidth,
+and the movement of the most visited URLs and most active IPs, highlighting
+changes of at least --threshold percent.
+`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		baseline, _ := cmd.Flags().GetString("baseline")
+		current, _ := cmd.Flags().GetString("current")
+		if baseline == "-" && current == "-" {
+			return fmt.Errorf("only one of --baseline and --current can be read from stdin")
+		}
+
+		return RunDiff(newArgLogReader(baseline), newArgLogReader(current), argLogName(baseline), argLogName(current))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().String("baseline", "", "log file for the baseline period")
+	diffCmd.Flags().String("current", "", "log file for the current period")
+	_ = diffCmd.MarkFlagRequired("baseline")
+	_ = diffCmd.MarkFlagRequired("current")
+
+	diffCmd.Flags().Float64("threshold", log.DefaultSignificantChange, "percentage change highlighted as significant")
+	_ = viper.BindPFlag("diff-threshold", diffCmd.Flags().Lookup("threshold"))
+}
+
+// RunDiff analyses the baseline and current logs and prints the differences between them.
+func RunDiff(baselineReader log.LogReader, currentReader log.LogReader, baselineName string, currentName string) error {
+	baseline, _, err := analyseLog(baselineReader, logParser, logEnricher, logFilter, logAnalyzer)
+	if err != nil {
+		return fmt.Errorf("baseline %s: %w", baselineName, err)
+	}
+
+	current, _, err := analyseLog(currentReader, logParser, logEnricher, logFilter, logAnalyzer)
+	if err != nil {
+		return fmt.Errorf("current %s: %w", currentName, err)
+	}
+
+	diff := log.CompareAnalyses(baseline, current, viper.GetFloat64("diff-threshold"))
+
+	renderer, err := render.NewDiffRenderer(viper.GetString("output"), tableOptions())
+	if err != nil {
+		return err
+	}
+
+	meta := reportMetadata()
+	meta.LogFile, meta.BaselineLogFile = currentName, baselineName
+
+	return renderer.RenderDiff(os.Stdout, meta, diff)
+}
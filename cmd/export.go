@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/export"
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/render"
+)
+
+var exportCmd = &cobra.Command{
+	Use:     "export csv|ndjson|sqlite [log-file]",
+	Aliases: []string{"convert"},
+	Short:   "Exports the parsed log entries to CSV, NDJSON or a SQLite database",
+	Long: `
+Exports the parsed log entries to CSV, NDJSON or a SQLite database
+
+Every log entry is exported with its parsed time, in UTC, and any GeoIP
+enrichment, response time and extra fields. CSV and NDJSON are written to
+stdout unless --file is set, e.g.
+  digio-task export ndjson access.log > access.ndjson
+
+SQLite databases are written to a new file, with the log entries in the
+logs table, indexed for ad-hoc SQL, e.g.
+  digio-task export sqlite --file access.db access.log
+  sqlite3 access.db "SELECT URL, count(*) FROM logs GROUP BY URL"
+
+The configured filter is applied, e.g. to export a period or only failures,
+and an empty export is written when no log entries match. A summary of any
+omitted log lines is printed to stderr.
+`,
+	Args:      cobra.RangeArgs(1, 2),
+	ValidArgs: []string{"csv", "ndjson", "sqlite"},
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			logReader = newArgLogReader(args[1])
+			viper.Set("log-file", argLogName(args[1]))
+		}
+
+		path, _ := cmd.Flags().GetString("file")
+
+		return RunExport(logReader, args[0], path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP("file", "f", "", "file to write, required for sqlite, stdout for csv and ndjson by default")
+}
+
+// RunExport exports the parsed log entries in a format: csv, ndjson or sqlite. CSV and NDJSON are written to
+// stdout when path is empty, and a summary of any omitted log lines to stderr.
+func RunExport(logReader log.LogReader, format string, path string) error {
+	if format != "csv" && format != "ndjson" && format != "sqlite" {
+		return fmt.Errorf("unknown export format: %s, expected csv, ndjson or sqlite", format)
+	}
+	if format == "sqlite" && path == "" {
+		return fmt.Errorf("a database file is required to export to sqlite, set --file")
+	}
+
+	// an export of no log entries is still written, e.g. a CSV header for a period with no requests
+	logEntries, parseReport, err := readLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return err
+	}
+	if err := render.RenderParseSummary(os.Stderr, parseReport); err != nil {
+		return err
+	}
+
+	if format == "sqlite" {
+		return export.WriteSQLite(path, logEntries)
+	}
+
+	if path == "" {
+		return writeExport(os.Stdout, format, logEntries)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating export file: %w", err)
+	}
+
+	if err := writeExport(f, format, logEntries); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeExport(w io.Writer, format string, logEntries []log.LogEntry) error {
+	if format == "csv" {
+		return export.WriteCSV(w, logEntries)
+	}
+
+	return export.WriteNDJSON(w, logEntries)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// RunIncremental analyses the lines added to a log file since the last run, merging them into the aggregates
+// kept in the state file, and prints the analysis of the whole log.
+func RunIncremental(logReader log.LogReader, statePath string) error {
+	fileReader, ok := logReader.(*log.FileReader)
+	if !ok {
+		return fmt.Errorf("incremental analysis requires a log file")
+	}
+
+	if len(viper.GetStringSlice("analyses")) > 0 || len(viper.GetStringSlice("group-by")) > 0 ||
+		viper.GetBool("approximate.enabled") || len(viper.GetStringSlice("geoip-databases")) > 0 {
+		return fmt.Errorf("optional analyses, grouping by extra fields, approximate mode and GeoIP analysis are not supported in incremental mode")
+	}
+
+	state, err := log.LoadIncrementalState(statePath)
+	if err != nil {
+		return err
+	}
+
+	filter := viper.GetString("filter")
+	switch {
+	case state == nil:
+		state = &log.IncrementalState{LogFilePath: fileReader.LogFilePath, Filter: filter, Snapshot: snapshotAnalyzer.NewSnapshot(nil)}
+	case state.LogFilePath != fileReader.LogFilePath:
+		return fmt.Errorf("incremental state %s is for log file %s", statePath, state.LogFilePath)
+	case state.Filter != filter:
+		return fmt.Errorf("incremental state %s was created with filter %q", statePath, state.Filter)
+	case !state.Snapshot.Config.Equal(snapshotAnalyzer.Config()):
+		return fmt.Errorf("incremental state %s was created with different url-normalisation or IP prefix length config", statePath)
+	}
+
+	reader := &log.IncrementalFileReader{LogFilePath: fileReader.LogFilePath, Checkpoint: state.Checkpoint}
+	logLines, err := reader.ReadLines()
+	if err != nil {
+		return fmt.Errorf("error reading log file: %w", err)
+	}
+
+	// a rotated or truncated log is read from the start, replacing the aggregates of the old log
+	if reader.Restarted {
+		state.Snapshot = snapshotAnalyzer.NewSnapshot(nil)
+	}
+
+	// no new lines, or only malformed ones, add no log entries, but are still checkpointed so they are not read
+	// again
+	logEntries, parseReport, err := logParser.ParseLogEntries(logLines)
+	if err != nil && !errors.Is(err, log.ErrNoLogEntries) {
+		return fmt.Errorf("error parsing log file: %w", err)
+	}
+
+	// number the issues from the start of the file, not of the lines added
+	parseReport.OffsetLineNumbers(reader.Checkpoint.LineCount - len(logLines))
+
+	logEntries = logEnricher.EnrichLogEntries(logEntries)
+	logEntries = logFilter.FilterLogEntries(logEntries)
+	snapshotAnalyzer.AddLogEntries(state.Snapshot, logEntries)
+
+	state.Checkpoint = reader.Checkpoint
+	if err := log.SaveIncrementalState(statePath, state); err != nil {
+		return fmt.Errorf("error saving incremental state: %w", err)
+	}
+
+	logAnalysis, err := snapshotAnalyzer.GetLogAnalysis(state.Snapshot, viper.GetInt("top-n"))
+	if err != nil {
+		return fmt.Errorf("error analysing log file: %w", err)
+	}
+
+	return printAnalysis(logAnalysis, parseReport)
+}
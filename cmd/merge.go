@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge snapshot-file...",
+	Short: "Combines analysis snapshots into a single analysis",
+	Long: `
+Combines analysis snapshots into a single analysis
+
+Snapshots are saved by analysing a log with --snapshot, e.g. for daily logs:
+  digio-task --snapshot monday.snap monday.log
+  digio-task --snapshot tuesday.snap tuesday.log
+  digio-task merge monday.snap tuesday.snap
+
+The combined analysis is exact, unique IP addresses are counted across all
+snapshots. The merged snapshot can itself be saved with --snapshot.
+
+Snapshots record the url-normalisation and IP prefix length config they were
+saved with, and can only be merged with the same config.
+`,
+	Args: cobra.MinimumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snapshotPath, _ := cmd.Flags().GetString("snapshot")
+
+		return RunMerge(args, snapshotPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().String("snapshot", "", "save the merged snapshot to a file")
+}
+
+// RunMerge merges snapshot files and prints the combined analysis. The merged snapshot is saved when
+// snapshotPath is set.
+func RunMerge(snapshotFiles []string, snapshotPath string) error {
+	merged, err := log.LoadSnapshot(snapshotFiles[0])
+	if err != nil {
+		return fmt.Errorf("error loading snapshot %s: %w", snapshotFiles[0], err)
+	}
+
+	for _, file := range snapshotFiles[1:] {
+		snapshot, err := log.LoadSnapshot(file)
+		if err != nil {
+			return fmt.Errorf("error loading snapshot %s: %w", file, err)
+		}
+
+		if err := merged.Merge(snapshot); err != nil {
+			return fmt.Errorf("error merging snapshot %s: %w", file, err)
+		}
+	}
+
+	logAnalysis, err := snapshotAnalyzer.GetLogAnalysis(merged, viper.GetInt("top-n"))
+	if err != nil {
+		return fmt.Errorf("error analysing snapshots: %w", err)
+	}
+
+	if snapshotPath != "" {
+		if err := log.SaveSnapshot(snapshotPath, merged); err != nil {
+			return fmt.Errorf("error saving snapshot: %w", err)
+		}
+	}
+
+	viper.Set("log-file", strings.Join(snapshotFiles, ", "))
+
+	return printAnalysis(logAnalysis, nil)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/export"
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/render"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query sql [log-file]",
+	Short: "Runs a SQL query over the parsed log entries",
+	Long: `
+Runs a SQL query over the parsed log entries
+
+The log entries are loaded into the logs table of an in-memory SQLite
+database, with the same columns as a SQLite export, e.g.
+  digio-task query "SELECT URL, count(*) FROM logs WHERE StatusCode >= 500 GROUP BY URL" access.log
+
+Columns are named after the log entry fields, and any extra fields: Time (UTC,
+RFC 3339), IP, Identity, UserID, Method, URL, Protocol, StatusCode, Size,
+Referrer, UserAgent, Country, ASN, ASOrg and ResponseTime (milliseconds).
+SQLite's functions are available, e.g. strftime('%H', Time) for the hour.
+
+Results are printed as a table, or with --output json or csv. The configured
+filter is applied before the query, which runs over an empty logs table when
+no log entries match. A summary of any omitted log lines is printed to stderr.
+`,
+	Args: cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			logReader = newArgLogReader(args[1])
+			viper.Set("log-file", argLogName(args[1]))
+		}
+
+		return RunQuery(logReader, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+}
+
+// RunQuery runs a SQL query over the parsed log entries, printing the results in the configured output format:
+// table, json or csv, and a summary of any omitted log lines to stderr.
+func RunQuery(logReader log.LogReader, query string) error {
+	renderer, err := render.NewQueryRenderer(viper.GetString("output"), tableOptions())
+	if err != nil {
+		return err
+	}
+
+	// a query over no log entries still runs, e.g. a count of zero for a period with no requests
+	logEntries, parseReport, err := readLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return err
+	}
+	if err := render.RenderParseSummary(os.Stderr, parseReport); err != nil {
+		return err
+	}
+
+	result, err := export.Query(logEntries, query)
+	if err != nil {
+		return err
+	}
+
+	meta := reportMetadata()
+	meta.Query = query
+
+	return renderer.RenderQuery(os.Stdout, meta, result)
+}
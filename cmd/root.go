@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 
 	"github.com/ryannortham/digio-task/log"
 	"github.com/ryannortham/digio-task/render"
@@ -15,9 +21,14 @@ import (
 var (
 	logReader   log.LogReader
 	logParser   log.LogParser
+	logEnricher log.LogEnricher
+	logFilter   log.LogFilter
 	logAnalyzer log.LogAnalyzer
 
+	snapshotAnalyzer *log.SnapshotAnalyzer
+
 	rootCmd = &cobra.Command{
+		Use:   "digio-task [log-file]",
 		Short: "Parses a log file containing HTTP requests and to reports on its contents",
 		Long: `
 Parses a log file containing HTTP requests and to reports on its contents
@@ -26,10 +37,38 @@ For a given log file we want to know:
 - The number of unique IP addresses
 - The top 3 most visited URLs
 - The top 3 most active IP addresses
+
+The log file can be given as an argument, overriding the configured log file.
+Use "-" to read the log from stdin, e.g. zcat access.log.gz | digio-task -
+
+Log entries can be filtered before analysis with a filter expression, e.g.
+  --filter 'Method == GET and StatusCode == 200 and IP in 168.41.191.0/24 and not UserAgent =~ "(?i)bot"'
+
+Use --snapshot to save the aggregates of the analysis, which can be combined
+with the snapshots of other logs by the merge command.
+
+Use --incremental state-file to only read the lines added to a growing log
+since the last run, e.g. from cron, merging them into the stored aggregates.
 `,
+		Args: cobra.MaximumNArgs(1),
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return Run(logReader, logParser, logAnalyzer)
+			if len(args) > 0 {
+				logReader = newArgLogReader(args[0])
+				viper.Set("log-file", argLogName(args[0]))
+			}
+
+			snapshotPath, _ := cmd.Flags().GetString("snapshot")
+
+			if statePath, _ := cmd.Flags().GetString("incremental"); statePath != "" {
+				if snapshotPath != "" {
+					return fmt.Errorf("--snapshot is not supported in incremental mode")
+				}
+
+				return RunIncremental(logReader, statePath)
+			}
+
+			return Run(logReader, logParser, logEnricher, logFilter, logAnalyzer, snapshotPath)
 		},
 	}
 )
@@ -45,32 +84,160 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	cobra.OnInitialize(initLogReader)
 	cobra.OnInitialize(initLogParser)
+	cobra.OnInitialize(initLogEnricher)
+	cobra.OnInitialize(initLogFilter)
 	cobra.OnInitialize(initLogAnalyzer)
+	cobra.OnInitialize(initColor)
+	cobra.OnFinalize(closeLogEnricher)
+
+	rootCmd.PersistentFlags().String("filter", "", "filter expression applied to log entries before analysis")
+	_ = viper.BindPFlag("filter", rootCmd.PersistentFlags().Lookup("filter"))
+
+	rootCmd.PersistentFlags().StringSlice("analyses", nil, "optional analyses to run: sessions, methods, users, referrers, latency, anomalies")
+	_ = viper.BindPFlag("analyses", rootCmd.PersistentFlags().Lookup("analyses"))
+
+	rootCmd.PersistentFlags().StringSlice("group-by", nil, "extra fields to report the top values of, named in the extra-fields config")
+	_ = viper.BindPFlag("group-by", rootCmd.PersistentFlags().Lookup("group-by"))
+
+	rootCmd.PersistentFlags().Bool("approximate", false, "use approximate counting for large logs")
+	_ = viper.BindPFlag("approximate.enabled", rootCmd.PersistentFlags().Lookup("approximate"))
+
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "output format: table, json or html, or csv for audits and queries")
+	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+
+	rootCmd.PersistentFlags().Bool("no-color", false, "disable coloured output, also disabled by the NO_COLOR environment variable")
+	_ = viper.BindPFlag("no-color", rootCmd.PersistentFlags().Lookup("no-color"))
+
+	rootCmd.PersistentFlags().Bool("no-banner", false, "omit the Digio logo")
+	_ = viper.BindPFlag("no-banner", rootCmd.PersistentFlags().Lookup("no-banner"))
+
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "omit the Digio logo and the list of parse errors and warnings")
+	_ = viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+
+	rootCmd.Flags().String("snapshot", "", "save a snapshot of the analysis to a file, for the merge command")
+	rootCmd.Flags().String("incremental", "", "state file for incremental analysis, only lines added since the last run are read")
 }
 
-func Run(logReader log.LogReader, logParser log.LogParser, logAnalyzer log.LogAnalyzer) error {
-	// read the log file
-	logLines, err := logReader.ReadLines()
+// Run analyses a log and prints the results. A snapshot of the analysis is saved when snapshotPath is set.
+func Run(logReader log.LogReader, logParser log.LogParser, logEnricher log.LogEnricher, logFilter log.LogFilter, logAnalyzer log.LogAnalyzer, snapshotPath string) error {
+	logEntries, parseReport, err := loadLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return err
+	}
+
+	// analyse the log file data
+	logAnalysis, err := logAnalyzer.GetLogAnalysis(logEntries, viper.GetInt("top-n"))
 	if err != nil {
-		return fmt.Errorf("error reading log file: %w", err)
+		return fmt.Errorf("error analysing log file: %w", err)
+	}
+
+	if snapshotPath != "" {
+		if err := log.SaveSnapshot(snapshotPath, snapshotAnalyzer.NewSnapshot(logEntries)); err != nil {
+			return fmt.Errorf("error saving snapshot: %w", err)
+		}
 	}
 
-	// parse the log file
-	logEntries, err := logParser.ParseLogEntries(logLines)
+	return printAnalysis(logAnalysis, parseReport)
+}
+
+// printAnalysis prints the analysis results in the configured output format.
+func printAnalysis(logAnalysis *log.LogAnalysis, parseReport *log.ParseReport) error {
+	renderer, err := render.NewRenderer(viper.GetString("output"), tableOptions())
 	if err != nil {
-		return fmt.Errorf("error parsing log file: %w", err)
+		return err
+	}
+
+	return renderer.RenderAnalysis(os.Stdout, reportMetadata(), logAnalysis, parseReport)
+}
+
+// reportMetadata describes the configured input and options of a report.
+func reportMetadata() render.ReportMetadata {
+	return render.ReportMetadata{
+		LogFile:     viper.GetString("log-file"),
+		TopN:        viper.GetInt("top-n"),
+		Filter:      viper.GetString("filter"),
+		Analyses:    viper.GetStringSlice("analyses"),
+		GeneratedAt: time.Now(),
+	}
+}
+
+// tableOptions adapts table output to stdout. The logo is only printed, and long values only truncated to
+// the terminal width, when stdout is a terminal.
+func tableOptions() render.TableOptions {
+	options := render.TableOptions{
+		NoBanner: viper.GetBool("no-banner"),
+		Quiet:    viper.GetBool("quiet"),
+	}
+
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		options.NoBanner = true
+		return options
+	}
+
+	if width, _, err := term.GetSize(fd); err == nil {
+		options.Width = width
+	}
+
+	return options
+}
+
+// analyseLog reads, parses, enriches, filters and analyses a log.
+func analyseLog(logReader log.LogReader, logParser log.LogParser, logEnricher log.LogEnricher, logFilter log.LogFilter, logAnalyzer log.LogAnalyzer) (*log.LogAnalysis, *log.ParseReport, error) {
+	logEntries, parseReport, err := loadLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// analyse the log file data
 	logAnalysis, err := logAnalyzer.GetLogAnalysis(logEntries, viper.GetInt("top-n"))
 	if err != nil {
-		return fmt.Errorf("error analysing log file: %w", err)
+		return nil, nil, fmt.Errorf("error analysing log file: %w", err)
+	}
+
+	return logAnalysis, parseReport, nil
+}
+
+// loadLogEntries reads, parses, enriches and filters the entries of a log, returning an error when no log
+// entries were parsed or match the filter.
+func loadLogEntries(logReader log.LogReader, logParser log.LogParser, logEnricher log.LogEnricher, logFilter log.LogFilter) ([]log.LogEntry, *log.ParseReport, error) {
+	logEntries, parseReport, err := readLogEntries(logReader, logParser, logEnricher, logFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parseReport.ParsedLines() == 0 {
+		return nil, nil, fmt.Errorf("error parsing log file: %w", log.ErrNoLogEntries)
+	}
+	if len(logEntries) == 0 {
+		return nil, nil, fmt.Errorf("no log entries match filter: %s", viper.GetString("filter"))
+	}
+
+	return logEntries, parseReport, nil
+}
+
+// readLogEntries reads, parses, enriches and filters the entries of a log, which may be none, e.g. for an
+// export of a period with no requests.
+func readLogEntries(logReader log.LogReader, logParser log.LogParser, logEnricher log.LogEnricher, logFilter log.LogFilter) ([]log.LogEntry, *log.ParseReport, error) {
+	// read the log file
+	logLines, err := logReader.ReadLines()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading log file: %w", err)
+	}
+
+	// parse the log file, where no log entries is not an error
+	logEntries, parseReport, err := logParser.ParseLogEntries(logLines)
+	if err != nil && !errors.Is(err, log.ErrNoLogEntries) {
+		return nil, nil, fmt.Errorf("error parsing log file: %w", err)
 	}
 
-	// print the results
-	render.PrintAnalysisResults(logAnalysis)
+	// enrich the log entries with GeoIP data
+	logEntries = logEnricher.EnrichLogEntries(logEntries)
+
+	// filter the log entries
+	logEntries = logFilter.FilterLogEntries(logEntries)
 
-	return nil
+	return logEntries, parseReport, nil
 }
 
 func initConfig() {
@@ -84,6 +251,14 @@ func initConfig() {
 	}
 }
 
+// initColor disables coloured output when requested. Colours are already disabled when stdout is not a
+// terminal, or the NO_COLOR environment variable is set.
+func initColor() {
+	if viper.GetBool("no-color") {
+		color.NoColor = true
+	}
+}
+
 func initLogReader() {
 	logSource := viper.GetString("log-source")
 
@@ -91,6 +266,9 @@ func initLogReader() {
 	case "file":
 		logFilePath := filepath.Join(viper.GetString("log-dir"), viper.GetString("log-file"))
 		logReader = &log.FileReader{LogFilePath: logFilePath}
+	case "stdin":
+		logReader = &log.StdinReader{}
+		viper.Set("log-file", "stdin")
 	case "api":
 		fmt.Println("API log source not yet implemented")
 		os.Exit(1)
@@ -100,12 +278,31 @@ func initLogReader() {
 	}
 }
 
+// newArgLogReader returns a reader for a log file passed as a command line argument,
+// where "-" denotes stdin.
+func newArgLogReader(arg string) log.LogReader {
+	if arg == "-" {
+		return &log.StdinReader{}
+	}
+
+	return &log.FileReader{LogFilePath: arg}
+}
+
+// argLogName returns the name of a log file passed as a command line argument, for display.
+func argLogName(arg string) string {
+	if arg == "-" {
+		return "stdin"
+	}
+
+	return arg
+}
+
 func initLogParser() {
 	logFormat := viper.GetString("log-format")
 
 	switch logFormat {
 	case "combined-log-format":
-		logParser = &log.CombinedLogParser{}
+		logParser = &log.CombinedLogParser{ResponseTime: newResponseTimeField(), ExtraFields: viper.GetStringSlice("extra-fields")}
 	case "common-log-format":
 		fmt.Println("Common log format not yet implemented")
 		os.Exit(1)
@@ -115,12 +312,173 @@ func initLogParser() {
 	}
 }
 
+// newResponseTimeField configures the parsing of response times from the fields after the user agent, or
+// returns nil when no response time field is configured.
+func newResponseTimeField() *log.ResponseTimeField {
+	position := viper.GetInt("response-time.field")
+	if position <= 0 {
+		return nil
+	}
+
+	unit, err := time.ParseDuration("1" + viper.GetString("response-time.unit"))
+	if err != nil {
+		fmt.Printf("Invalid response time unit %q, expected us, ms or s\n", viper.GetString("response-time.unit"))
+		os.Exit(1)
+	}
+
+	return &log.ResponseTimeField{Position: position, Unit: unit}
+}
+
+func initLogEnricher() {
+	var databases []log.IPDatabase
+	for _, path := range viper.GetStringSlice("geoip-databases") {
+		db, err := log.OpenIPDatabase(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		databases = append(databases, db)
+	}
+
+	logEnricher = &log.GeoIPEnricher{Databases: databases}
+}
+
+// closeLogEnricher closes the GeoIP databases opened by initLogEnricher.
+func closeLogEnricher() {
+	if enricher, ok := logEnricher.(*log.GeoIPEnricher); ok {
+		if err := enricher.Close(); err != nil {
+			fmt.Printf("Error closing GeoIP databases: %s\n", err)
+		}
+	}
+}
+
+func initLogFilter() {
+	filter, err := log.NewExpressionFilter(viper.GetString("filter"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	logFilter = filter
+}
+
+// newAnomalyDetector configures anomaly detection, adding any configured sensitive paths to the defaults.
+func newAnomalyDetector() *log.AnomalyDetector {
+	sensitivePaths := slices.Clone(log.DefaultSensitivePaths)
+	for _, pattern := range viper.GetStringSlice("anomalies.sensitive-paths") {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			fmt.Printf("Invalid sensitive path pattern %q: %s\n", pattern, err)
+			os.Exit(1)
+		}
+
+		sensitivePaths = append(sensitivePaths, log.SensitivePath{Name: pattern, Pattern: regex, Severity: log.SeverityMedium})
+	}
+
+	return &log.AnomalyDetector{
+		RateWindow:            viper.GetDuration("anomalies.rate-window"),
+		RateThreshold:         viper.GetInt("anomalies.rate-threshold"),
+		ErrorRatioThreshold:   viper.GetFloat64("anomalies.error-ratio-threshold"),
+		ErrorRatioMinRequests: viper.GetInt("anomalies.error-ratio-min-requests"),
+		SensitivePaths:        sensitivePaths,
+	}
+}
+
 func initLogAnalyzer() {
 	logFormat := viper.GetString("log-format")
 
+	var urlConfig log.URLNormaliserConfig
+	if err := viper.UnmarshalKey("url-normalisation", &urlConfig); err != nil {
+		fmt.Printf("Error reading url-normalisation config: %s\n", err)
+		os.Exit(1)
+	}
+
+	urlNormaliser, err := log.NewURLNormaliser(urlConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	analyzer := &log.CombinedLogAnalyzer{
+		URLNormaliser: urlNormaliser,
+		IPv4PrefixLen: viper.GetInt("ipv4-prefix-length"),
+		IPv6PrefixLen: viper.GetInt("ipv6-prefix-length"),
+		GeoIP:         len(viper.GetStringSlice("geoip-databases")) > 0,
+		GroupBy:       viper.GetStringSlice("group-by"),
+	}
+
+	// 0 leaves IPs ungrouped
+	if analyzer.IPv4PrefixLen < 0 || analyzer.IPv4PrefixLen > 32 {
+		fmt.Printf("Invalid ipv4-prefix-length: %d, must be between 0 and 32\n", analyzer.IPv4PrefixLen)
+		os.Exit(1)
+	}
+	if analyzer.IPv6PrefixLen < 0 || analyzer.IPv6PrefixLen > 128 {
+		fmt.Printf("Invalid ipv6-prefix-length: %d, must be between 0 and 128\n", analyzer.IPv6PrefixLen)
+		os.Exit(1)
+	}
+
+	for _, field := range analyzer.GroupBy {
+		if !slices.Contains(viper.GetStringSlice("extra-fields"), field) || field == "-" {
+			fmt.Printf("Unknown extra field to group by: %s, extra fields are named in the extra-fields config\n", field)
+			os.Exit(1)
+		}
+	}
+
+	snapshotAnalyzer = &log.SnapshotAnalyzer{
+		URLNormaliser: urlNormaliser,
+		IPv4PrefixLen: analyzer.IPv4PrefixLen,
+		IPv6PrefixLen: analyzer.IPv6PrefixLen,
+	}
+
+	for _, analysis := range viper.GetStringSlice("analyses") {
+		switch analysis {
+		case "sessions":
+			analyzer.Sessions = &log.SessionAnalyzer{Timeout: viper.GetDuration("session-timeout")}
+		case "methods":
+			analyzer.Methods = &log.MethodAnalyzer{}
+		case "users":
+			analyzer.Users = &log.UserAnalyzer{}
+		case "referrers":
+			analyzer.Referrers = &log.ReferrerAnalyzer{InternalHosts: viper.GetStringSlice("referrers.internal-hosts")}
+		case "latency":
+			analyzer.Latency = &log.LatencyAnalyzer{Interval: viper.GetDuration("latency-interval")}
+		case "anomalies":
+			analyzer.Anomalies = newAnomalyDetector()
+		default:
+			fmt.Printf("Unknown analysis: %s\n", analysis)
+			os.Exit(1)
+		}
+	}
+
+	if viper.GetBool("approximate.enabled") {
+		if len(viper.GetStringSlice("analyses")) > 0 || analyzer.GeoIP || len(analyzer.GroupBy) > 0 {
+			fmt.Println("Optional analyses, GeoIP analysis and grouping by extra fields are not supported in approximate mode")
+			os.Exit(1)
+		}
+
+		// 0 uses the default precision
+		precision := viper.GetInt("approximate.hll-precision")
+		if precision != 0 && (precision < log.MinHyperLogLogPrecision || precision > log.MaxHyperLogLogPrecision) {
+			fmt.Printf("Invalid approximate.hll-precision: %d, must be between %d and %d\n",
+				precision, log.MinHyperLogLogPrecision, log.MaxHyperLogLogPrecision)
+			os.Exit(1)
+		}
+
+		logAnalyzer = &log.ApproximateLogAnalyzer{
+			URLNormaliser: urlNormaliser,
+			IPv4PrefixLen: analyzer.IPv4PrefixLen,
+			IPv6PrefixLen: analyzer.IPv6PrefixLen,
+			Precision:     uint8(precision),
+			Epsilon:       viper.GetFloat64("approximate.epsilon"),
+			Delta:         viper.GetFloat64("approximate.delta"),
+		}
+		return
+	}
+
 	switch logFormat {
 	case "combined-log-format":
-		logAnalyzer = &log.CombinedLogAnalyzer{}
+		logAnalyzer = analyzer
 	case "common-log-format":
 		fmt.Println("Common log format not yet implemented")
 		os.Exit(1)
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/server"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [log-file]",
+	Short: "Serves the analysis of a log file over a JSON HTTP API",
+	Long: `
+Serves the analysis of a log file over a JSON HTTP API
+
+Endpoints:
+  /analysis      the analysis of the log
+  /top/urls      the most visited URLs
+  /top/ips       the most active IP addresses
+  /status-codes  the number of requests for each status code
+  /metrics       Prometheus metrics of the requests in the log
+
+The /analysis and /top endpoints accept n, the number of top values to report.
+All endpoints accept since and until, limiting the analysis to a period, e.g.
+  curl 'localhost:8080/top/urls?n=10&since=1h'
+
+Use --follow to tail the log file, analysing lines as they are added.
+`,
+	Args: cobra.MaximumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			logReader = newArgLogReader(args[0])
+			viper.Set("log-file", argLogName(args[0]))
+		}
+
+		return RunServe(logReader)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("address", "localhost:8080", "address to listen on")
+	_ = viper.BindPFlag("serve.address", serveCmd.Flags().Lookup("address"))
+
+	serveCmd.Flags().Bool("follow", false, "tail the log file, analysing lines as they are added")
+	_ = viper.BindPFlag("serve.follow", serveCmd.Flags().Lookup("follow"))
+}
+
+// RunServe loads the log and serves its analysis, tailing the log file when following is enabled.
+func RunServe(logReader log.LogReader) error {
+	// requests may ask for more top values than there are, which are all reported
+	if analyzer, ok := logAnalyzer.(*log.CombinedLogAnalyzer); ok {
+		analyzer.ClampTopN = true
+	}
+
+	srv := server.NewServer(logAnalyzer, viper.GetString("log-file"), viper.GetInt("top-n"))
+	metrics, err := server.NewMetrics(snapshotAnalyzer.URLNormaliser.Config())
+	if err != nil {
+		return err
+	}
+
+	fileReader, isFile := logReader.(*log.FileReader)
+	follow := viper.GetBool("serve.follow")
+
+	switch {
+	case follow && !isFile:
+		return fmt.Errorf("only log files can be followed")
+	case follow:
+		tail := &log.IncrementalFileReader{LogFilePath: fileReader.LogFilePath}
+		if err := readNewLogEntries(srv, metrics, tail); err != nil {
+			return err
+		}
+
+		go followLog(srv, metrics, tail, viper.GetDuration("serve.poll-interval"))
+	default:
+		logEntries, parseReport, err := loadLogEntries(logReader, logParser, logEnricher, logFilter)
+		if err != nil {
+			return err
+		}
+
+		srv.SetLogEntries(logEntries)
+		metrics.Observe(logEntries, parseReport)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", srv.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+
+	address := viper.GetString("serve.address")
+	fmt.Printf("Serving the analysis of %s on http://%s\n", viper.GetString("log-file"), address)
+
+	return http.ListenAndServe(address, mux)
+}
+
+// followLog polls the log file for new lines until the program exits.
+func followLog(srv *server.Server, metrics *server.Metrics, tail *log.IncrementalFileReader, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	for range time.Tick(interval) {
+		if err := readNewLogEntries(srv, metrics, tail); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// readNewLogEntries adds the entries of lines added to the log file since it was last read. When the log file
+// has been rotated or truncated, the entries replace those held by the server, while the metrics keep counting.
+func readNewLogEntries(srv *server.Server, metrics *server.Metrics, tail *log.IncrementalFileReader) error {
+	logLines, err := tail.ReadLines()
+	if err != nil {
+		return fmt.Errorf("error reading log file: %w", err)
+	}
+
+	// no new lines, or only malformed ones, are an empty batch, which still replaces the entries of a rotated log
+	logEntries, parseReport, err := logParser.ParseLogEntries(logLines)
+	if err != nil && !errors.Is(err, log.ErrNoLogEntries) {
+		return fmt.Errorf("error parsing log file: %w", err)
+	}
+
+	logEntries = logEnricher.EnrichLogEntries(logEntries)
+	logEntries = logFilter.FilterLogEntries(logEntries)
+
+	if tail.Restarted {
+		srv.SetLogEntries(logEntries)
+	} else {
+		srv.AddLogEntries(logEntries)
+	}
+	metrics.Observe(logEntries, parseReport)
+
+	return nil
+}
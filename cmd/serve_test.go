@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/server"
+)
+
+func Test_readNewLogEntries(t *testing.T) {
+	parser, enricher, filter := logParser, logEnricher, logFilter
+	t.Cleanup(func() { logParser, logEnricher, logFilter = parser, enricher, filter })
+
+	logParser = &log.CombinedLogParser{}
+	logEnricher = &log.GeoIPEnricher{}
+	logFilter = &log.ExpressionFilter{}
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	writeLog := func(flag int, lines string) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		assert.NoError(t, err)
+		_, err = f.WriteString(lines)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+	}
+
+	srv := server.NewServer(&log.CombinedLogAnalyzer{ClampTopN: true}, "access.log", 2)
+	metrics, err := server.NewMetrics(log.URLNormaliserConfig{})
+	assert.NoError(t, err)
+	tail := &log.IncrementalFileReader{LogFilePath: path}
+
+	get := func(target string) (int, string) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		body, err := io.ReadAll(rec.Body)
+		assert.NoError(t, err)
+		return rec.Code, string(body)
+	}
+
+	writeLog(os.O_TRUNC, `177.71.128.21 - - [10/Jul/2018:22:21:28 +0200] "GET /intranet-analytics/ HTTP/1.1" 200 3574 "-" "curl/7.61.0"
+168.41.191.40 - - [09/Jul/2018:10:11:30 +0200] "GET /faq/ HTTP/1.1" 404 0 "-" "curl/7.61.0"
+`)
+	assert.NoError(t, readNewLogEntries(srv, metrics, tail))
+
+	// an idle poll, with no new lines, is not an error
+	assert.NoError(t, readNewLogEntries(srv, metrics, tail))
+
+	// an all-malformed batch adds no entries, but is counted
+	writeLog(os.O_APPEND, "malformed line\nanother malformed line\n")
+	assert.NoError(t, readNewLogEntries(srv, metrics, tail))
+
+	code, body := get("/status-codes")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"logFile":"access.log","statusCodes":{"200":1,"404":1}}`, body)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "digio_log_lines_total 4")
+	assert.Contains(t, rec.Body.String(), "digio_log_parse_errors_total 2")
+
+	// a log rotated to only malformed lines replaces the entries of the old log
+	writeLog(os.O_TRUNC, "malformed\n")
+	assert.NoError(t, readNewLogEntries(srv, metrics, tail))
+	assert.True(t, tail.Restarted)
+
+	code, _ = get("/top/urls")
+	assert.Equal(t, http.StatusNotFound, code)
+}
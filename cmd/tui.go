@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/tui"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [log-file]",
+	Short: "Explores the analysis of a log file interactively",
+	Long: `
+Explores the analysis of a log file interactively
+
+The log is parsed once and held in memory. Switch between the analyses with
+tab, change the top N with + and -, sort the columns with s and r, list the
+requests of an IP with enter, and type a filter expression after /, which is
+applied as it is typed.
+`,
+	Args: cobra.MaximumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			logReader = newArgLogReader(args[0])
+			viper.Set("log-file", argLogName(args[0]))
+		}
+
+		return RunTUI(logReader)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
+
+// RunTUI reads, parses and enriches a log, and explores its analysis interactively. The configured filter is
+// the initial filter, and can be changed in the interactive view.
+func RunTUI(logReader log.LogReader) error {
+	if _, ok := logReader.(*log.StdinReader); ok {
+		return fmt.Errorf("the interactive view reads keys from stdin, so the log must be read from a file")
+	}
+
+	logLines, err := logReader.ReadLines()
+	if err != nil {
+		return fmt.Errorf("error reading log file: %w", err)
+	}
+
+	logEntries, _, err := logParser.ParseLogEntries(logLines)
+	if err != nil {
+		return fmt.Errorf("error parsing log file: %w", err)
+	}
+
+	logEntries = logEnricher.EnrichLogEntries(logEntries)
+
+	model, err := tui.NewModel(logAnalyzer, logEntries, viper.GetString("log-file"), viper.GetInt("top-n"), viper.GetString("filter"))
+	if err != nil {
+		return fmt.Errorf("error analysing log file: %w", err)
+	}
+
+	return tui.Run(model)
+}
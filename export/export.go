@@ -0,0 +1,207 @@
+// Package export writes parsed log entries to files for other tools: CSV, newline delimited JSON, or a SQLite
+// database for ad-hoc SQL.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// Column is a column of exported log entries. Columns are named after their LogEntry field, or after their
+// extra field.
+type Column struct {
+	Name string
+	// Type is the SQLite type of the column: TEXT, INTEGER or REAL
+	Type  string
+	value func(log.LogEntry) any
+}
+
+// Value returns the value of a log entry in the column: a string, int, float64, or nil when it was not logged.
+func (c Column) Value(entry log.LogEntry) any {
+	return c.value(entry)
+}
+
+// entryColumns are the columns of the LogEntry fields, in the order they are logged, followed by enrichments.
+var entryColumns = []Column{
+	{Name: "Time", Type: "TEXT", value: func(e log.LogEntry) any { return entryTime(e) }},
+	{Name: "IP", Type: "TEXT", value: func(e log.LogEntry) any { return e.IP }},
+	{Name: "Identity", Type: "TEXT", value: func(e log.LogEntry) any { return e.Identity }},
+	{Name: "UserID", Type: "TEXT", value: func(e log.LogEntry) any { return e.UserID }},
+	{Name: "Method", Type: "TEXT", value: func(e log.LogEntry) any { return e.Method }},
+	{Name: "URL", Type: "TEXT", value: func(e log.LogEntry) any { return e.URL }},
+	{Name: "Protocol", Type: "TEXT", value: func(e log.LogEntry) any { return e.Protocol }},
+	{Name: "StatusCode", Type: "INTEGER", value: func(e log.LogEntry) any { return e.StatusCode }},
+	{Name: "Size", Type: "INTEGER", value: func(e log.LogEntry) any { return e.Size }},
+	{Name: "Referrer", Type: "TEXT", value: func(e log.LogEntry) any { return e.Referrer }},
+	{Name: "UserAgent", Type: "TEXT", value: func(e log.LogEntry) any { return e.UserAgent }},
+	{Name: "Country", Type: "TEXT", value: func(e log.LogEntry) any { return e.Country }},
+	{Name: "ASN", Type: "INTEGER", value: func(e log.LogEntry) any { return e.ASN }},
+	{Name: "ASOrg", Type: "TEXT", value: func(e log.LogEntry) any { return e.ASOrg }},
+	// ResponseTime is in milliseconds
+	{Name: "ResponseTime", Type: "REAL", value: func(e log.LogEntry) any {
+		if e.ResponseTime == nil {
+			return nil
+		}
+		return float64(*e.ResponseTime) / float64(time.Millisecond)
+	}},
+}
+
+// entryTime returns the time of a log entry in UTC as RFC 3339, so that times sort and compare as text, or the
+// time as logged if it could not be parsed.
+func entryTime(entry log.LogEntry) string {
+	if entry.Timestamp.IsZero() {
+		return entry.Time
+	}
+
+	return entry.Timestamp.UTC().Format(time.RFC3339)
+}
+
+// Columns returns the columns of log entries: their LogEntry fields, followed by the extra fields of any of the
+// log entries, in name order. Column names which are the same ignoring case are an error, as they could not be
+// told apart in SQL.
+func Columns(logEntries []log.LogEntry) ([]Column, error) {
+	extraNames := make(map[string]bool)
+	for _, entry := range logEntries {
+		for name := range entry.Extra {
+			extraNames[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(extraNames))
+	for name := range extraNames {
+		for _, column := range entryColumns {
+			if strings.EqualFold(name, column.Name) {
+				return nil, fmt.Errorf("extra field %q has the same name as the %s column", name, column.Name)
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// sorted names which are the same ignoring case are not always adjacent, e.g. VHost, request-id, vhost
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("extra fields %q and %q have the same name ignoring case", other, name)
+		}
+		seen[strings.ToLower(name)] = name
+	}
+
+	columns := append([]Column(nil), entryColumns...)
+	for _, name := range names {
+		name := name
+		columns = append(columns, Column{Name: name, Type: "TEXT", value: func(e log.LogEntry) any {
+			if value, ok := e.Extra[name]; ok {
+				return value
+			}
+			return nil
+		}})
+	}
+
+	return columns, nil
+}
+
+// WriteCSV writes log entries as CSV, with a header row of column names. Values which were not logged are
+// empty.
+func WriteCSV(w io.Writer, logEntries []log.LogEntry) error {
+	columns, err := Columns(logEntries)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	for _, entry := range logEntries {
+		for i, column := range columns {
+			record[i] = formatValue(column.Value(entry))
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	return nil
+}
+
+// formatValue formats a column value as text, or "" when it was not logged.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteNDJSON writes log entries as newline delimited JSON, one object per line with a key for each column in
+// column order. Values which were not logged are null.
+func WriteNDJSON(w io.Writer, logEntries []log.LogEntry) error {
+	columns, err := Columns(logEntries)
+	if err != nil {
+		return err
+	}
+
+	keys := make([][]byte, len(columns))
+	for i, column := range columns {
+		if keys[i], err = json.Marshal(column.Name); err != nil {
+			return fmt.Errorf("error encoding column %s: %w", column.Name, err)
+		}
+	}
+
+	var line bytes.Buffer
+	for _, entry := range logEntries {
+		line.Reset()
+		line.WriteByte('{')
+
+		for i, column := range columns {
+			value, err := json.Marshal(column.Value(entry))
+			if err != nil {
+				return fmt.Errorf("error encoding %s: %w", column.Name, err)
+			}
+
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.Write(keys[i])
+			line.WriteByte(':')
+			line.Write(value)
+		}
+
+		line.WriteString("}\n")
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return fmt.Errorf("error writing ndjson: %w", err)
+		}
+	}
+
+	return nil
+}
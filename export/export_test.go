@@ -0,0 +1,143 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func testLogEntries() []log.LogEntry {
+	responseTime := 1500 * time.Microsecond
+
+	return []log.LogEntry{
+		{
+			IP: "177.71.128.21", Identity: "-", UserID: "admin", Time: "10/Jul/2018:22:21:28 +0200",
+			Timestamp: time.Date(2018, 7, 10, 22, 21, 28, 0, time.FixedZone("", 2*60*60)),
+			Method:    "GET", URL: "/intranet-analytics/", Protocol: "HTTP/1.1", StatusCode: 200, Size: 3574,
+			Referrer: "-", UserAgent: `Mozilla/5.0 (X11; "quoted")`, Country: "BR", ASN: 64501, ASOrg: "Example Telecom",
+			ResponseTime: &responseTime,
+			Extra:        map[string]string{"vhost": "example.com", "request-id": "abc"},
+		},
+		{
+			IP: "168.41.191.40", Identity: "-", UserID: "-", Time: "invalid",
+			Method: "GET", URL: "/faq/", Protocol: "HTTP/1.1", StatusCode: 404, Size: 0,
+			Referrer: "-", UserAgent: "curl/7.61.0",
+			Extra: map[string]string{"vhost": "example.net"},
+		},
+	}
+}
+
+func Test_WriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, testLogEntries()))
+
+	want := `Time,IP,Identity,UserID,Method,URL,Protocol,StatusCode,Size,Referrer,UserAgent,Country,ASN,ASOrg,ResponseTime,request-id,vhost
+2018-07-10T20:21:28Z,177.71.128.21,-,admin,GET,/intranet-analytics/,HTTP/1.1,200,3574,-,"Mozilla/5.0 (X11; ""quoted"")",BR,64501,Example Telecom,1.5,abc,example.com
+invalid,168.41.191.40,-,-,GET,/faq/,HTTP/1.1,404,0,-,curl/7.61.0,,0,,,,example.net
+`
+	assert.Equal(t, want, buf.String())
+}
+
+func Test_WriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteNDJSON(&buf, testLogEntries()))
+
+	want := `{"Time":"2018-07-10T20:21:28Z","IP":"177.71.128.21","Identity":"-","UserID":"admin","Method":"GET","URL":"/intranet-analytics/","Protocol":"HTTP/1.1","StatusCode":200,"Size":3574,"Referrer":"-","UserAgent":"Mozilla/5.0 (X11; \"quoted\")","Country":"BR","ASN":64501,"ASOrg":"Example Telecom","ResponseTime":1.5,"request-id":"abc","vhost":"example.com"}
+{"Time":"invalid","IP":"168.41.191.40","Identity":"-","UserID":"-","Method":"GET","URL":"/faq/","Protocol":"HTTP/1.1","StatusCode":404,"Size":0,"Referrer":"-","UserAgent":"curl/7.61.0","Country":"","ASN":0,"ASOrg":"","ResponseTime":null,"request-id":null,"vhost":"example.net"}
+`
+	assert.Equal(t, want, buf.String())
+}
+
+func Test_export_noLogEntries(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, nil))
+	assert.Equal(t, "Time,IP,Identity,UserID,Method,URL,Protocol,StatusCode,Size,Referrer,UserAgent,Country,ASN,ASOrg,ResponseTime\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteNDJSON(&buf, nil))
+	assert.Empty(t, buf.String())
+
+	path := filepath.Join(t.TempDir(), "logs.db")
+	assert.NoError(t, WriteSQLite(path, nil))
+
+	db, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	assert.NoError(t, db.QueryRow(`SELECT count(*) FROM logs`).Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+func Test_Columns_extraFieldNameClash(t *testing.T) {
+	_, err := Columns([]log.LogEntry{{Extra: map[string]string{"url": "/"}}})
+	assert.EqualError(t, err, `extra field "url" has the same name as the URL column`)
+
+	_, err = Columns([]log.LogEntry{
+		{Extra: map[string]string{"vhost": "example.com", "request-id": "abc"}},
+		{Extra: map[string]string{"VHost": "example.net"}},
+	})
+	assert.EqualError(t, err, `extra fields "VHost" and "vhost" have the same name ignoring case`)
+}
+
+func Test_WriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	assert.NoError(t, WriteSQLite(path, testLogEntries()))
+
+	db, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT URL, StatusCode, ResponseTime, "request-id" FROM logs ORDER BY StatusCode`)
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	type row struct {
+		url          string
+		statusCode   int
+		responseTime sql.NullFloat64
+		requestID    sql.NullString
+	}
+
+	var got []row
+	for rows.Next() {
+		var r row
+		assert.NoError(t, rows.Scan(&r.url, &r.statusCode, &r.responseTime, &r.requestID))
+		got = append(got, r)
+	}
+	assert.NoError(t, rows.Err())
+
+	assert.Equal(t, []row{
+		{url: "/intranet-analytics/", statusCode: 200, responseTime: sql.NullFloat64{Float64: 1.5, Valid: true}, requestID: sql.NullString{String: "abc", Valid: true}},
+		{url: "/faq/", statusCode: 404},
+	}, got)
+
+	var indexes int
+	assert.NoError(t, db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'logs'`).Scan(&indexes))
+	assert.Equal(t, 7, indexes)
+
+	// an existing database is not overwritten
+	assert.EqualError(t, WriteSQLite(path, testLogEntries()), "database "+path+" already exists")
+}
+
+func Test_WriteSQLite_error(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.db")
+
+	clash := []log.LogEntry{{Extra: map[string]string{"vhost": "example.com"}}, {Extra: map[string]string{"VHost": "example.net"}}}
+	assert.Error(t, WriteSQLite(path, clash))
+
+	// a failed export leaves no file behind, so it can be retried
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	assert.NoError(t, WriteSQLite(path, testLogEntries()))
+}
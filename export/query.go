@@ -0,0 +1,80 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// QueryResult is the result of a SQL query over log entries.
+type QueryResult struct {
+	Columns []string
+	// Rows are the values of each row: nil for NULL, or an int64, float64 or string
+	Rows [][]any
+}
+
+// Query runs a SQL query over log entries, which are loaded into the logs table of an in-memory SQLite
+// database, with the same columns as a SQLite export.
+func Query(logEntries []log.LogEntry, query string) (*QueryResult, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	defer db.Close()
+
+	// every connection to :memory: opens a new, empty database, so the log entries must be queried on the
+	// connection they were loaded on
+	db.SetMaxOpenConns(1)
+
+	if err := LoadSQLite(db, logEntries); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	result := &QueryResult{}
+	if result.Columns, err = rows.Columns(); err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+
+	for rows.Next() {
+		values := make([]any, len(result.Columns))
+		pointers := make([]any, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("error reading query results: %w", err)
+		}
+
+		// blobs are returned as text, so that they can be rendered
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+
+		result.Rows = append(result.Rows, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+
+	return result, nil
+}
+
+// FormatValue formats a value of a query result as text, or "NULL" for NULL.
+func FormatValue(value any) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	return formatValue(value)
+}
@@ -0,0 +1,27 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Query(t *testing.T) {
+	got, err := Query(testLogEntries(), `SELECT vhost, count(*) AS Requests, sum(ResponseTime) AS ResponseTime FROM logs WHERE StatusCode >= 200 GROUP BY vhost ORDER BY vhost`)
+	assert.NoError(t, err)
+	assert.Equal(t, &QueryResult{
+		Columns: []string{"vhost", "Requests", "ResponseTime"},
+		Rows: [][]any{
+			{"example.com", int64(1), 1.5},
+			{"example.net", int64(1), nil},
+		},
+	}, got)
+
+	_, err = Query(testLogEntries(), "SELECT * FROM requests")
+	assert.ErrorContains(t, err, "no such table: requests")
+
+	// the logs table exists when there are no log entries
+	got, err = Query(nil, "SELECT count(*) AS Requests FROM logs")
+	assert.NoError(t, err)
+	assert.Equal(t, &QueryResult{Columns: []string{"Requests"}, Rows: [][]any{{int64(0)}}}, got)
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// pure Go SQLite driver, registered as "sqlite", so databases can be written without cgo
+	_ "modernc.org/sqlite"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// TableName is the table of log entries in SQLite databases.
+const TableName = "logs"
+
+// indexedColumns are the LogEntry columns which are indexed for common queries. Extra fields are also indexed.
+var indexedColumns = []string{"Time", "IP", "URL", "StatusCode", "UserID"}
+
+// WriteSQLite writes log entries to the logs table of a new SQLite database file. An existing file is not
+// overwritten. The database is written to a temporary file in the same directory, which is renamed once it is
+// complete, so a failed export leaves no file behind.
+func WriteSQLite(path string, logEntries []log.LogEntry) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("database %s already exists", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error opening database %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating database %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := writeSQLiteFile(tmpPath, logEntries); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error creating database %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeSQLiteFile loads log entries into the SQLite database file at path.
+func writeSQLiteFile(path string, logEntries []log.LogEntry) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("error opening database %s: %w", path, err)
+	}
+
+	if err := LoadSQLite(db, logEntries); err != nil {
+		db.Close()
+		return err
+	}
+
+	return db.Close()
+}
+
+// LoadSQLite creates the logs table in a database, with a column per Column of the log entries, inserts the
+// log entries and indexes the table.
+func LoadSQLite(db *sql.DB, logEntries []log.LogEntry) error {
+	columns, err := Columns(logEntries)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error loading log entries: %w", err)
+	}
+	defer tx.Rollback()
+
+	definitions := make([]string, len(columns))
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = quoteIdentifier(column.Name) + " " + column.Type
+		names[i] = quoteIdentifier(column.Name)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", TableName, strings.Join(definitions, ", "))); err != nil {
+		return fmt.Errorf("error creating %s table: %w", TableName, err)
+	}
+
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		TableName, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+	if err != nil {
+		return fmt.Errorf("error loading log entries: %w", err)
+	}
+	defer insert.Close()
+
+	values := make([]any, len(columns))
+	for _, entry := range logEntries {
+		for i, column := range columns {
+			values[i] = column.Value(entry)
+		}
+		if _, err := insert.Exec(values...); err != nil {
+			return fmt.Errorf("error loading log entries: %w", err)
+		}
+	}
+
+	// index after inserting, which is faster than maintaining the indexes for every insert
+	indexed := append([]string(nil), indexedColumns...)
+	for _, column := range columns[len(entryColumns):] {
+		indexed = append(indexed, column.Name)
+	}
+
+	for _, name := range indexed {
+		index := quoteIdentifier(TableName + "_" + name)
+		if _, err := tx.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (%s)", index, TableName, quoteIdentifier(name))); err != nil {
+			return fmt.Errorf("error indexing %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error loading log entries: %w", err)
+	}
+
+	return nil
+}
+
+// quoteIdentifier quotes a table, column or index name, which may contain characters such as "-" in extra
+// field names.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
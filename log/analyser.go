@@ -4,21 +4,84 @@ import (
 	"fmt"
 
 	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
 )
 
 type LogAnalysis struct {
+	RequestCount int
+	// ErrorRate is the proportion of requests with a 4xx or 5xx status code
+	ErrorRate float64
+	// TotalBytes is the bandwidth used by response bodies
+	TotalBytes          int
 	UniqueIPCount       int
 	TopNMostVisitedURLs [][]string
 	TopNMostActiveIPs   [][]string
+	// TopNCountries and TopNASNs are only set for log entries enriched with GeoIP data
+	TopNCountries [][]string
+	TopNASNs      [][]string
+	// GroupedBy are the top values of each extra field requests are grouped by
+	GroupedBy []ExtraFieldValues
+	// Sessions is only set when session analysis is enabled
+	Sessions *SessionAnalysis
+	// Methods is only set when method analysis is enabled
+	Methods *MethodAnalysis
+	// Users is only set when user analysis is enabled
+	Users *UserAnalysis
+	// Referrers is only set when referrer analysis is enabled
+	Referrers *ReferrerAnalysis
+	// Latency is only set when latency analysis is enabled
+	Latency *LatencyAnalysis
+	// Findings are only set when anomaly detection is enabled
+	Findings []Finding
+	// Approximation is only set by the ApproximateLogAnalyzer, giving the error bounds of the results
+	Approximation *ApproximationBounds
+}
+
+// ExtraFieldValues are the most common values of an extra field, e.g. the busiest virtual hosts.
+type ExtraFieldValues struct {
+	Field      string
+	TopNValues [][]string
 }
 
 type LogAnalyzer interface {
 	GetLogAnalysis([]LogEntry, int) (*LogAnalysis, error)
 }
 
-type CombinedLogAnalyzer struct{}
+type CombinedLogAnalyzer struct {
+	// URLNormaliser is applied to URLs before they are grouped, URLs are grouped verbatim when nil.
+	URLNormaliser *URLNormaliser
+	// IPv4PrefixLen and IPv6PrefixLen group the most active IPs by network prefix, e.g. /24 or /64.
+	// IPs are not grouped when 0.
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+	// GeoIP enables analysis of the countries and networks of log entries enriched by a GeoIPEnricher.
+	GeoIP bool
+	// GroupBy are the names of extra fields, parsed from after the user agent, to report the top values of.
+	GroupBy []string
+	// Sessions enables session reconstruction and visitor journey analysis when not nil.
+	Sessions *SessionAnalyzer
+	// Methods enables the breakdown of requests by method and protocol version when not nil.
+	Methods *MethodAnalyzer
+	// Users enables analysis of the activity of authenticated users when not nil.
+	Users *UserAnalyzer
+	// Referrers enables referrer analysis and traffic source attribution when not nil.
+	Referrers *ReferrerAnalyzer
+	// Latency enables analysis of the response times of log entries parsed with a response time when not nil.
+	Latency *LatencyAnalyzer
+	// Anomalies enables security anomaly detection when not nil.
+	Anomalies *AnomalyDetector
+	// ClampTopN reports all the URLs and IPs when there are fewer than topN, rather than returning an error.
+	ClampTopN bool
+}
 
 func (l *CombinedLogAnalyzer) GetLogAnalysis(logEntries []LogEntry, topN int) (*LogAnalysis, error) {
+	// anomalies are detected in the original URLs, before normalisation can hide them
+	rawEntries := logEntries
+
+	if l.URLNormaliser != nil {
+		logEntries = normaliseURLs(logEntries, l.URLNormaliser)
+	}
+
 	df := dataframe.LoadStructs(logEntries)
 	if df.Err != nil {
 		return nil, df.Err
@@ -29,30 +92,192 @@ func (l *CombinedLogAnalyzer) GetLogAnalysis(logEntries []LogEntry, topN int) (*
 		return nil, err
 	}
 
-	URLGroups, err := aggregateDfByColumn(df, "URL")
-	if err != nil {
-		return nil, err
+	activeIPGroups := IPGroups
+	if l.IPv4PrefixLen > 0 || l.IPv6PrefixLen > 0 {
+		activeIPGroups, err = l.aggregateByIPPrefix(df, logEntries)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	topActiveIPs, err := getTopNRows(IPGroups, topN)
+	ipTopN := topN
+	if l.ClampTopN {
+		ipTopN = min(topN, activeIPGroups.Nrow())
+	}
+
+	topActiveIPs, err := getTopNRows(activeIPGroups, ipTopN)
 	if err != nil {
 		return nil, err
 	}
 
-	topVisitedURLs, err := getTopNRows(URLGroups, topN)
+	topVisitedURLs, err := l.getTopVisitedURLs(df, topN)
 	if err != nil {
 		return nil, err
 	}
 
+	errorCount, totalBytes := 0, 0
+	for _, entry := range logEntries {
+		if entry.StatusCode >= 400 {
+			errorCount++
+		}
+		totalBytes += entry.Size
+	}
+
 	la := &LogAnalysis{
+		RequestCount:        len(logEntries),
+		ErrorRate:           float64(errorCount) / float64(len(logEntries)),
+		TotalBytes:          totalBytes,
 		UniqueIPCount:       IPGroups.Nrow(),
 		TopNMostActiveIPs:   topActiveIPs.Records(),
-		TopNMostVisitedURLs: topVisitedURLs.Records(),
+		TopNMostVisitedURLs: topVisitedURLs,
+	}
+
+	if l.GeoIP {
+		if err := l.analyseGeoIP(df, logEntries, topN, la); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range l.GroupBy {
+		topValues, err := getTopNValues(field, extraFieldValues(logEntries, field), topN)
+		if err != nil {
+			return nil, err
+		}
+
+		la.GroupedBy = append(la.GroupedBy, ExtraFieldValues{Field: field, TopNValues: topValues})
+	}
+
+	if l.Sessions != nil {
+		if la.Sessions, err = l.Sessions.GetSessionAnalysis(logEntries, topN); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Methods != nil {
+		if la.Methods, err = l.Methods.GetMethodAnalysis(logEntries, topN); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Users != nil {
+		if la.Users, err = l.Users.GetUserAnalysis(logEntries, topN); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Referrers != nil {
+		// the hosts of the site are taken from the original URLs, before normalisation can strip them
+		if la.Referrers, err = l.Referrers.GetReferrerAnalysis(logEntries, RequestHosts(rawEntries), topN); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Latency != nil {
+		if la.Latency, err = l.Latency.GetLatencyAnalysis(logEntries, topN); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Anomalies != nil {
+		la.Findings = l.Anomalies.GetFindings(rawEntries)
 	}
 
 	return la, nil
 }
 
+// analyseGeoIP adds the top countries and networks to the log analysis.
+func (l *CombinedLogAnalyzer) analyseGeoIP(df dataframe.DataFrame, logEntries []LogEntry, topN int, la *LogAnalysis) error {
+	countryGroups, err := aggregateDfByColumn(df, "Country")
+	if err != nil {
+		return err
+	}
+
+	// label networks with their organisation, e.g. AS64500 Example Networks
+	networks := make([]string, len(logEntries))
+	for i, entry := range logEntries {
+		networks[i] = "-"
+		if entry.ASN != 0 {
+			networks[i] = fmt.Sprintf("AS%d %s", entry.ASN, entry.ASOrg)
+		}
+	}
+
+	df = df.Mutate(series.New(networks, series.String, "Network"))
+	if df.Err != nil {
+		return df.Err
+	}
+
+	networkGroups, err := aggregateDfByColumn(df, "Network")
+	if err != nil {
+		return err
+	}
+
+	la.TopNCountries = getTopNRecords(countryGroups, topN)
+	la.TopNASNs = getTopNRecords(networkGroups, topN)
+
+	return nil
+}
+
+// extraFieldValues returns the values of an extra field of the log entries, or "-" for entries without it.
+func extraFieldValues(logEntries []LogEntry, field string) []string {
+	values := make([]string, len(logEntries))
+	for i, entry := range logEntries {
+		value, ok := entry.Extra[field]
+		if !ok {
+			value = "-"
+		}
+		values[i] = value
+	}
+
+	return values
+}
+
+// getTopVisitedURLs returns the records of the top n URLs. Malformed requests have no URL to rank, see
+// MalformedRequest, so a log of only malformed requests has no URLs.
+func (l *CombinedLogAnalyzer) getTopVisitedURLs(df dataframe.DataFrame, topN int) ([][]string, error) {
+	urlDf := df.Filter(
+		dataframe.F{Colname: "Method", Comparator: series.Neq, Comparando: "-"},
+		dataframe.F{Colname: "Protocol", Comparator: series.Neq, Comparando: "-"},
+	)
+	if urlDf.Err != nil {
+		return nil, urlDf.Err
+	}
+
+	if urlDf.Nrow() == 0 {
+		return [][]string{{"URL", "URL_COUNT"}}, nil
+	}
+
+	URLGroups, err := aggregateDfByColumn(urlDf, "URL")
+	if err != nil {
+		return nil, err
+	}
+
+	if l.ClampTopN {
+		topN = min(topN, URLGroups.Nrow())
+	}
+
+	topURLs, err := getTopNRows(URLGroups, topN)
+	if err != nil {
+		return nil, err
+	}
+
+	return topURLs.Records(), nil
+}
+
+// aggregateByIPPrefix groups the log entries by the network prefix of their IP.
+func (l *CombinedLogAnalyzer) aggregateByIPPrefix(df dataframe.DataFrame, logEntries []LogEntry) (*dataframe.DataFrame, error) {
+	prefixes := make([]string, len(logEntries))
+	for i, entry := range logEntries {
+		prefixes[i] = IPPrefix(entry.IP, l.IPv4PrefixLen, l.IPv6PrefixLen)
+	}
+
+	df = df.Mutate(series.New(prefixes, series.String, "IPPrefix"))
+	if df.Err != nil {
+		return nil, df.Err
+	}
+
+	return aggregateDfByColumn(df, "IPPrefix")
+}
+
 func aggregateDfByColumn(df dataframe.DataFrame, colName string) (*dataframe.DataFrame, error) {
 	if !columnExists(df, colName) {
 		return nil, fmt.Errorf("column %s does not exist", colName)
@@ -91,6 +316,32 @@ func getTopNRows(df *dataframe.DataFrame, n int) (dataframe.DataFrame, error) {
 	return sortedDf.Subset(indices), nil
 }
 
+// getTopNValues counts the occurrences of each value, returning the records of the n most common values.
+func getTopNValues(colName string, values []string, n int) ([][]string, error) {
+	if len(values) == 0 {
+		return [][]string{{colName, colName + "_COUNT"}}, nil
+	}
+
+	df := dataframe.New(series.New(values, series.String, colName))
+	groups, err := aggregateDfByColumn(df, colName)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTopNRecords(groups, n), nil
+}
+
+// getTopNRecords returns the records of the top n rows, or all rows when there are fewer than n.
+func getTopNRecords(df *dataframe.DataFrame, n int) [][]string {
+	topN, err := getTopNRows(df, min(n, df.Nrow()))
+	if err != nil {
+		// this should never happen, n is clamped to the number of rows
+		return nil
+	}
+
+	return topN.Records()
+}
+
 // checks that a column exists in a dataframe
 func columnExists(df dataframe.DataFrame, colName string) bool {
 	for _, name := range df.Names() {
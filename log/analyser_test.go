@@ -169,6 +169,13 @@ func Test_CombinedLogAnalyzer_GetLogAnalysis(t *testing.T) {
 		{IP: "192.168.0.2", URL: "/about"},
 	}
 
+	logEntriesWithResponses := []LogEntry{
+		{IP: "192.168.0.1", URL: "/home", StatusCode: 200, Size: 100},
+		{IP: "192.168.0.2", URL: "/about", StatusCode: 404, Size: 50},
+		{IP: "192.168.0.1", URL: "/home", StatusCode: 200, Size: 100},
+		{IP: "192.168.0.3", URL: "/contact", StatusCode: 500, Size: 0},
+	}
+
 	tests := []struct {
 		name    string
 		entries []LogEntry
@@ -181,12 +188,27 @@ func Test_CombinedLogAnalyzer_GetLogAnalysis(t *testing.T) {
 			entries: logEntries,
 			topN:    2,
 			want: &LogAnalysis{
+				RequestCount:        6,
 				UniqueIPCount:       3,
 				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}, {"192.168.0.2", "2.000000"}},
 				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
 			},
 			wantErr: false,
 		},
+		{
+			name:    "get log analysis counts errors and bandwidth",
+			entries: logEntriesWithResponses,
+			topN:    1,
+			want: &LogAnalysis{
+				RequestCount:        4,
+				ErrorRate:           0.5,
+				TotalBytes:          250,
+				UniqueIPCount:       3,
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "2.000000"}},
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "2.000000"}},
+			},
+			wantErr: false,
+		},
 		{
 			name:    "log analysis with empty input throws error",
 			entries: []LogEntry{},
@@ -221,3 +243,105 @@ func Test_CombinedLogAnalyzer_GetLogAnalysis(t *testing.T) {
 		})
 	}
 }
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_malformedRequests(t *testing.T) {
+	entries := []LogEntry{
+		{IP: "192.168.0.1", StatusCode: 200, Method: "GET", URL: "/home", Protocol: "HTTP/1.1"},
+		{IP: "192.168.0.2", StatusCode: 408, Method: "-", URL: "-", Protocol: "-"},
+		{IP: "192.168.0.3", StatusCode: 408, Method: "-", URL: "-", Protocol: "-"},
+	}
+
+	got, err := (&CombinedLogAnalyzer{}).GetLogAnalysis(entries, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got.RequestCount)
+	assert.Equal(t, [][]string{{"URL", "URL_COUNT"}, {"/home", "1.000000"}}, got.TopNMostVisitedURLs)
+}
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_clampTopN(t *testing.T) {
+	entries := []LogEntry{
+		{IP: "192.168.0.1", StatusCode: 200, URL: "/home"},
+		{IP: "192.168.0.1", StatusCode: 200, URL: "/about"},
+		{IP: "192.168.0.1", StatusCode: 404, URL: "/home"},
+	}
+
+	got, err := (&CombinedLogAnalyzer{ClampTopN: true}).GetLogAnalysis(entries, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}}, got.TopNMostActiveIPs)
+	assert.Equal(t, [][]string{{"URL", "URL_COUNT"}, {"/home", "2.000000"}, {"/about", "1.000000"}}, got.TopNMostVisitedURLs)
+}
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_normalisesURLs(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "192.168.0.1", URL: "http://example.net/faq/"},
+		{IP: "192.168.0.2", URL: "/faq/"},
+		{IP: "192.168.0.1", URL: "/users/1"},
+		{IP: "192.168.0.3", URL: "/users/2?tab=posts"},
+		{IP: "192.168.0.1", URL: "/users/3"},
+	}
+
+	normaliser, err := NewURLNormaliser(URLNormaliserConfig{
+		StripHost: true,
+		DropQuery: true,
+		Patterns:  []string{"/users/{id}"},
+	})
+	assert.NoError(t, err)
+
+	l := &CombinedLogAnalyzer{URLNormaliser: normaliser}
+	got, err := l.GetLogAnalysis(logEntries, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"URL", "URL_COUNT"}, {"/users/{id}", "3.000000"}, {"/faq/", "2.000000"}}, got.TopNMostVisitedURLs)
+
+	// the caller's log entries are not modified
+	assert.Equal(t, "http://example.net/faq/", logEntries[0].URL)
+}
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_groupsIPsByPrefix(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "/home"},
+		{IP: "168.41.191.41", URL: "/home"},
+		{IP: "168.41.191.40", URL: "/about"},
+		{IP: "2001:db8::1", URL: "/home"},
+		{IP: "2001:db8::2", URL: "/about"},
+		{IP: "177.71.128.21", URL: "/contact"},
+	}
+
+	l := &CombinedLogAnalyzer{IPv4PrefixLen: 24, IPv6PrefixLen: 64}
+	got, err := l.GetLogAnalysis(logEntries, 2)
+	assert.NoError(t, err)
+
+	// unique IPs are still counted by address
+	assert.Equal(t, 5, got.UniqueIPCount)
+	assert.Equal(t, [][]string{{"IPPrefix", "IPPrefix_COUNT"}, {"168.41.191.0/24", "3.000000"}, {"2001:db8::/64", "2.000000"}}, got.TopNMostActiveIPs)
+}
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_geoIP(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "/home", Country: "AU", ASN: 64500, ASOrg: "Example Networks"},
+		{IP: "168.41.191.41", URL: "/home", Country: "AU", ASN: 64500, ASOrg: "Example Networks"},
+		{IP: "177.71.128.21", URL: "/about", Country: "BR", ASN: 64501, ASOrg: "Example Telecom"},
+		{IP: "10.0.0.1", URL: "/contact", Country: "-", ASOrg: "-"},
+	}
+
+	l := &CombinedLogAnalyzer{GeoIP: true}
+	got, err := l.GetLogAnalysis(logEntries, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Country", "Country_COUNT"}, {"AU", "2.000000"}, {"-", "1.000000"}, {"BR", "1.000000"}}, got.TopNCountries)
+	assert.Equal(t, [][]string{{"Network", "Network_COUNT"}, {"AS64500 Example Networks", "2.000000"}, {"-", "1.000000"}, {"AS64501 Example Telecom", "1.000000"}}, got.TopNASNs)
+}
+
+func Test_CombinedLogAnalyzer_GetLogAnalysis_groupBy(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "/home", Extra: map[string]string{"vhost": "example.com", "upstream": "10.0.0.1:80"}},
+		{IP: "168.41.191.41", URL: "/home", Extra: map[string]string{"vhost": "example.com", "upstream": "10.0.0.2:80"}},
+		{IP: "177.71.128.21", URL: "/about", Extra: map[string]string{"vhost": "example.net"}},
+		{IP: "10.0.0.1", URL: "/contact"},
+	}
+
+	l := &CombinedLogAnalyzer{GroupBy: []string{"vhost", "upstream"}}
+	got, err := l.GetLogAnalysis(logEntries, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ExtraFieldValues{
+		{Field: "vhost", TopNValues: [][]string{{"vhost", "vhost_COUNT"}, {"example.com", "2.000000"}, {"-", "1.000000"}}},
+		{Field: "upstream", TopNValues: [][]string{{"upstream", "upstream_COUNT"}, {"-", "2.000000"}, {"10.0.0.1:80", "1.000000"}}},
+	}, got.GroupedBy)
+}
@@ -0,0 +1,287 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityLow Severity = iota + 1
+	SeverityMedium
+	SeverityHigh
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is a suspicious pattern of requests found by an AnomalyDetector.
+type Finding struct {
+	Severity Severity
+	Kind     string // rate-spike, scanner, sensitive-path or unusual-method
+	Subject  string // the IP or method responsible
+	Detail   string
+	Count    int // the number of requests involved
+}
+
+// SensitivePath matches requests for paths which are commonly probed by attackers.
+type SensitivePath struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Severity Severity
+}
+
+// DefaultSensitivePaths are used when an AnomalyDetector has no SensitivePaths configured.
+var DefaultSensitivePaths = []SensitivePath{
+	{Name: "path traversal", Pattern: regexp.MustCompile(`(?i)(\.|%2e){2}(/|\\|%2f|%5c)`), Severity: SeverityHigh},
+	{Name: "environment file", Pattern: regexp.MustCompile(`(?i)/\.env\b`), Severity: SeverityHigh},
+	{Name: "version control", Pattern: regexp.MustCompile(`(?i)/\.(git|svn|hg)(/|$)`), Severity: SeverityHigh},
+	{Name: "system file", Pattern: regexp.MustCompile(`(?i)/etc/(passwd|shadow)`), Severity: SeverityHigh},
+	{Name: "WordPress admin", Pattern: regexp.MustCompile(`(?i)/(wp-admin|wp-login\.php|xmlrpc\.php)`), Severity: SeverityMedium},
+	{Name: "database admin", Pattern: regexp.MustCompile(`(?i)/(phpmyadmin|pma|adminer)(/|\.php|$)`), Severity: SeverityMedium},
+}
+
+// standardMethods are the HTTP methods expected from well behaved clients.
+var standardMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true, "OPTIONS": true, "PATCH": true,
+}
+
+// dangerousMethods are non-standard methods which are commonly used to probe servers.
+var dangerousMethods = map[string]bool{
+	"TRACE": true, "TRACK": true, "CONNECT": true, "DEBUG": true,
+}
+
+// AnomalyDetector finds security related anomalies in log entries. Zero valued thresholds use the defaults.
+type AnomalyDetector struct {
+	// RateWindow and RateThreshold flag IPs making at least RateThreshold requests within a sliding window.
+	RateWindow    time.Duration
+	RateThreshold int
+	// ErrorRatioThreshold flags IPs with a ratio of 4xx responses at or above the threshold, such as scanners,
+	// for IPs with at least ErrorRatioMinRequests requests.
+	ErrorRatioThreshold   float64
+	ErrorRatioMinRequests int
+	SensitivePaths        []SensitivePath
+}
+
+const (
+	defaultRateWindow            = time.Minute
+	defaultRateThreshold         = 60
+	defaultErrorRatioThreshold   = 0.5
+	defaultErrorRatioMinRequests = 10
+)
+
+// GetFindings returns anomalies found in the log entries, ranked by severity and number of requests.
+func (d *AnomalyDetector) GetFindings(logEntries []LogEntry) []Finding {
+	findings := make([]Finding, 0)
+	findings = append(findings, d.findRateSpikes(logEntries)...)
+	findings = append(findings, d.findScanners(logEntries)...)
+	findings = append(findings, d.findSensitivePaths(logEntries)...)
+	findings = append(findings, findUnusualMethods(logEntries)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		if findings[i].Count != findings[j].Count {
+			return findings[i].Count > findings[j].Count
+		}
+		if findings[i].Subject != findings[j].Subject {
+			return findings[i].Subject < findings[j].Subject
+		}
+		return findings[i].Detail < findings[j].Detail
+	})
+
+	return findings
+}
+
+// findRateSpikes flags IPs with a burst of requests within the rate window.
+func (d *AnomalyDetector) findRateSpikes(logEntries []LogEntry) []Finding {
+	window := valueOrDefault(d.RateWindow, defaultRateWindow)
+	threshold := valueOrDefault(d.RateThreshold, defaultRateThreshold)
+
+	timestamps := make(map[string][]time.Time)
+	for _, entry := range logEntries {
+		if !entry.Timestamp.IsZero() {
+			timestamps[entry.IP] = append(timestamps[entry.IP], entry.Timestamp)
+		}
+	}
+
+	var findings []Finding
+	for ip, times := range timestamps {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		// slide the window over the sorted timestamps, tracking the busiest window
+		maxCount, maxStart := 0, time.Time{}
+		start := 0
+		for end := range times {
+			for times[end].Sub(times[start]) >= window {
+				start++
+			}
+			if count := end - start + 1; count > maxCount {
+				maxCount, maxStart = count, times[start]
+			}
+		}
+
+		if maxCount < threshold {
+			continue
+		}
+
+		severity := SeverityMedium
+		if maxCount >= 2*threshold {
+			severity = SeverityHigh
+		}
+
+		findings = append(findings, Finding{
+			Severity: severity,
+			Kind:     "rate-spike",
+			Subject:  ip,
+			Detail:   fmt.Sprintf("%d requests within %s from %s", maxCount, window, maxStart.Format(LogTimeLayout)),
+			Count:    maxCount,
+		})
+	}
+
+	return findings
+}
+
+// findScanners flags IPs where a high proportion of requests result in 4xx client errors.
+func (d *AnomalyDetector) findScanners(logEntries []LogEntry) []Finding {
+	ratioThreshold := valueOrDefault(d.ErrorRatioThreshold, defaultErrorRatioThreshold)
+	minRequests := valueOrDefault(d.ErrorRatioMinRequests, defaultErrorRatioMinRequests)
+
+	requests := make(map[string]int)
+	clientErrors := make(map[string]int)
+	for _, entry := range logEntries {
+		requests[entry.IP]++
+		if entry.StatusCode >= 400 && entry.StatusCode < 500 {
+			clientErrors[entry.IP]++
+		}
+	}
+
+	var findings []Finding
+	for ip, total := range requests {
+		ratio := float64(clientErrors[ip]) / float64(total)
+		if total < minRequests || ratio < ratioThreshold {
+			continue
+		}
+
+		severity := SeverityMedium
+		if ratio >= 0.9 {
+			severity = SeverityHigh
+		}
+
+		findings = append(findings, Finding{
+			Severity: severity,
+			Kind:     "scanner",
+			Subject:  ip,
+			Detail:   fmt.Sprintf("%d of %d requests (%.0f%%) were 4xx client errors", clientErrors[ip], total, ratio*100),
+			Count:    clientErrors[ip],
+		})
+	}
+
+	return findings
+}
+
+// findSensitivePaths flags IPs requesting paths commonly probed by attackers.
+func (d *AnomalyDetector) findSensitivePaths(logEntries []LogEntry) []Finding {
+	sensitivePaths := d.SensitivePaths
+	if sensitivePaths == nil {
+		sensitivePaths = DefaultSensitivePaths
+	}
+
+	type probe struct {
+		ip   string
+		path int
+	}
+	counts := make(map[probe]int)
+	examples := make(map[probe]string)
+
+	for _, entry := range logEntries {
+		for i, sp := range sensitivePaths {
+			if sp.Pattern.MatchString(entry.URL) {
+				p := probe{ip: entry.IP, path: i}
+				counts[p]++
+				if _, ok := examples[p]; !ok {
+					examples[p] = entry.URL
+				}
+				break
+			}
+		}
+	}
+
+	var findings []Finding
+	for p, count := range counts {
+		sp := sensitivePaths[p.path]
+		findings = append(findings, Finding{
+			Severity: sp.Severity,
+			Kind:     "sensitive-path",
+			Subject:  p.ip,
+			Detail:   fmt.Sprintf("%d requests for %s paths, e.g. %s", count, sp.Name, examples[p]),
+			Count:    count,
+		})
+	}
+
+	return findings
+}
+
+// findUnusualMethods flags requests using non-standard HTTP methods.
+func findUnusualMethods(logEntries []LogEntry) []Finding {
+	counts := make(map[string]int)
+	ips := make(map[string]map[string]bool)
+
+	for _, entry := range logEntries {
+		method := strings.ToUpper(entry.Method)
+		if standardMethods[method] || MalformedRequest(entry) {
+			continue
+		}
+
+		counts[entry.Method]++
+		if ips[entry.Method] == nil {
+			ips[entry.Method] = make(map[string]bool)
+		}
+		ips[entry.Method][entry.IP] = true
+	}
+
+	var findings []Finding
+	for method, count := range counts {
+		severity := SeverityLow
+		if dangerousMethods[strings.ToUpper(method)] {
+			severity = SeverityMedium
+		}
+
+		findings = append(findings, Finding{
+			Severity: severity,
+			Kind:     "unusual-method",
+			Subject:  method,
+			Detail:   fmt.Sprintf("%d requests from %d IPs", count, len(ips[method])),
+			Count:    count,
+		})
+	}
+
+	return findings
+}
+
+func valueOrDefault[T comparable](value, defaultValue T) T {
+	var zero T
+	if value == zero {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AnomalyDetector_GetFindings(t *testing.T) {
+	start := time.Date(2018, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	// requests from an IP every second, for the given number of seconds
+	burst := func(ip string, seconds int, statusCode int) []LogEntry {
+		entries := make([]LogEntry, seconds)
+		for i := range entries {
+			entries[i] = LogEntry{IP: ip, Method: "GET", URL: fmt.Sprintf("/page/%d", i), StatusCode: statusCode, Timestamp: start.Add(time.Duration(i) * time.Second)}
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name     string
+		detector *AnomalyDetector
+		entries  []LogEntry
+		want     []Finding
+	}{
+		{
+			name:     "no anomalies",
+			detector: &AnomalyDetector{},
+			entries: []LogEntry{
+				{IP: "192.168.0.1", Method: "GET", URL: "/", StatusCode: 200, Timestamp: start},
+				{IP: "192.168.0.1", Method: "POST", URL: "/login", StatusCode: 404, Timestamp: start},
+			},
+			want: []Finding{},
+		},
+		{
+			name:     "rate spike within window",
+			detector: &AnomalyDetector{RateWindow: 10 * time.Second, RateThreshold: 5},
+			entries:  burst("192.168.0.1", 10, 200),
+			want: []Finding{
+				{Severity: SeverityHigh, Kind: "rate-spike", Subject: "192.168.0.1", Detail: "10 requests within 10s from 10/Jul/2018:12:00:00 +0000", Count: 10},
+			},
+		},
+		{
+			name:     "requests spread beyond window are not a spike",
+			detector: &AnomalyDetector{RateWindow: 3 * time.Second, RateThreshold: 5},
+			entries:  burst("192.168.0.1", 10, 200),
+			want:     []Finding{},
+		},
+		{
+			name:     "scanner with high 4xx ratio",
+			detector: &AnomalyDetector{ErrorRatioThreshold: 0.5, ErrorRatioMinRequests: 3},
+			entries:  append(burst("192.168.0.1", 3, 404), burst("192.168.0.2", 3, 200)...),
+			want: []Finding{
+				{Severity: SeverityHigh, Kind: "scanner", Subject: "192.168.0.1", Detail: "3 of 3 requests (100%) were 4xx client errors", Count: 3},
+			},
+		},
+		{
+			name:     "too few requests to be a scanner",
+			detector: &AnomalyDetector{ErrorRatioMinRequests: 4},
+			entries:  burst("192.168.0.1", 3, 404),
+			want:     []Finding{},
+		},
+		{
+			name:     "sensitive paths",
+			detector: &AnomalyDetector{},
+			entries: []LogEntry{
+				{IP: "192.168.0.1", Method: "GET", URL: "/wp-admin/install.php", StatusCode: 200},
+				{IP: "192.168.0.1", Method: "GET", URL: "/wp-login.php", StatusCode: 200},
+				{IP: "192.168.0.2", Method: "GET", URL: "/static/..%2f..%2fetc/passwd", StatusCode: 200},
+				{IP: "192.168.0.3", Method: "GET", URL: "/.env", StatusCode: 200},
+				{IP: "192.168.0.3", Method: "GET", URL: "/environment/", StatusCode: 200},
+			},
+			want: []Finding{
+				{Severity: SeverityHigh, Kind: "sensitive-path", Subject: "192.168.0.2", Detail: "1 requests for path traversal paths, e.g. /static/..%2f..%2fetc/passwd", Count: 1},
+				{Severity: SeverityHigh, Kind: "sensitive-path", Subject: "192.168.0.3", Detail: "1 requests for environment file paths, e.g. /.env", Count: 1},
+				{Severity: SeverityMedium, Kind: "sensitive-path", Subject: "192.168.0.1", Detail: "2 requests for WordPress admin paths, e.g. /wp-admin/install.php", Count: 2},
+			},
+		},
+		{
+			name: "custom sensitive paths",
+			detector: &AnomalyDetector{SensitivePaths: []SensitivePath{
+				{Name: "internal", Pattern: regexp.MustCompile(`^/internal/`), Severity: SeverityLow},
+			}},
+			entries: []LogEntry{
+				{IP: "192.168.0.1", Method: "GET", URL: "/internal/metrics", StatusCode: 200},
+				{IP: "192.168.0.1", Method: "GET", URL: "/.env", StatusCode: 200},
+			},
+			want: []Finding{
+				{Severity: SeverityLow, Kind: "sensitive-path", Subject: "192.168.0.1", Detail: "1 requests for internal paths, e.g. /internal/metrics", Count: 1},
+			},
+		},
+		{
+			name:     "unusual methods",
+			detector: &AnomalyDetector{},
+			entries: []LogEntry{
+				{IP: "192.168.0.1", Method: "TRACE", URL: "/", StatusCode: 405},
+				{IP: "192.168.0.2", Method: "PROPFIND", URL: "/", StatusCode: 405},
+				{IP: "192.168.0.3", Method: "PROPFIND", URL: "/", StatusCode: 405},
+				// malformed request lines are parse warnings, not unusual methods
+				{IP: "192.168.0.4", Method: "-", URL: "-", Protocol: "-", StatusCode: 408},
+			},
+			want: []Finding{
+				{Severity: SeverityMedium, Kind: "unusual-method", Subject: "TRACE", Detail: "1 requests from 1 IPs", Count: 1},
+				{Severity: SeverityLow, Kind: "unusual-method", Subject: "PROPFIND", Detail: "2 requests from 2 IPs", Count: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.detector.GetFindings(tt.entries)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_Severity_MarshalText(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{severity: SeverityLow, want: "low"},
+		{severity: SeverityMedium, want: "medium"},
+		{severity: SeverityHigh, want: "high"},
+		{severity: 0, want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := tt.severity.MarshalText()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"fmt"
+	"math"
+)
+
+// Default sketch parameters for the ApproximateLogAnalyzer.
+const (
+	DefaultHyperLogLogPrecision = 14
+	DefaultSketchEpsilon        = 0.001
+	DefaultSketchDelta          = 0.01
+)
+
+// ApproximateLogAnalyzer analyses log entries in bounded memory using probabilistic sketches, for inputs too
+// large to group exactly. Unique IPs are counted with a HyperLogLog, and the most visited URLs and most active
+// IPs are found with Space-Saving, with counts refined by a Count-Min Sketch.
+type ApproximateLogAnalyzer struct {
+	URLNormaliser *URLNormaliser
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+	// Precision of the HyperLogLog, the unique count has a relative standard error of 1.04/sqrt(2^Precision)
+	Precision uint8
+	// Epsilon and Delta bound the overcount of top N counts to Epsilon times the number of requests,
+	// with probability 1 - Delta
+	Epsilon float64
+	Delta   float64
+}
+
+// ApproximationBounds are the error bounds of an approximate LogAnalysis.
+type ApproximationBounds struct {
+	// UniqueCountError is the relative standard error of UniqueIPCount
+	UniqueCountError float64
+	// CountErrorBound is the maximum overcount of the top N counts, at the given Confidence
+	CountErrorBound int
+	Confidence      float64
+}
+
+func (l *ApproximateLogAnalyzer) GetLogAnalysis(logEntries []LogEntry, topN int) (*LogAnalysis, error) {
+	if len(logEntries) == 0 {
+		return nil, fmt.Errorf("no log entries to analyse")
+	}
+
+	precision := l.Precision
+	if precision == 0 {
+		precision = DefaultHyperLogLogPrecision
+	}
+	epsilon := valueOrDefault(l.Epsilon, DefaultSketchEpsilon)
+	delta := valueOrDefault(l.Delta, DefaultSketchDelta)
+
+	uniqueIPs, err := NewHyperLogLog(precision)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := newHeavyHitters(epsilon, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := newHeavyHitters(epsilon, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	ipColumn := "IP"
+	if l.IPv4PrefixLen > 0 || l.IPv6PrefixLen > 0 {
+		ipColumn = "IPPrefix"
+	}
+
+	la := &LogAnalysis{RequestCount: len(logEntries)}
+	errorCount := 0
+
+	for _, entry := range logEntries {
+		url := entry.URL
+		if l.URLNormaliser != nil {
+			url = l.URLNormaliser.Normalise(url)
+		}
+
+		uniqueIPs.Add(entry.IP)
+		if !MalformedRequest(entry) {
+			urls.add(url)
+		}
+		ips.add(IPPrefix(entry.IP, l.IPv4PrefixLen, l.IPv6PrefixLen))
+
+		if entry.StatusCode >= 400 {
+			errorCount++
+		}
+		la.TotalBytes += entry.Size
+	}
+
+	la.ErrorRate = float64(errorCount) / float64(len(logEntries))
+	la.UniqueIPCount = uniqueIPs.Count()
+	la.TopNMostVisitedURLs = urls.topNRecords("URL", topN)
+	la.TopNMostActiveIPs = ips.topNRecords(ipColumn, topN)
+	la.Approximation = &ApproximationBounds{
+		UniqueCountError: uniqueIPs.RelativeError(),
+		CountErrorBound:  urls.counts.ErrorBound(),
+		Confidence:       1 - delta,
+	}
+
+	return la, nil
+}
+
+// heavyHitters finds the most frequent values, using Space-Saving to track candidates and a Count-Min Sketch
+// to refine their counts. Both overcount, so the smaller of the two counts is the better estimate.
+type heavyHitters struct {
+	candidates *SpaceSaving
+	counts     *CountMinSketch
+}
+
+func newHeavyHitters(epsilon, delta float64) (*heavyHitters, error) {
+	counts, err := NewCountMinSketch(epsilon, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	// with 1/epsilon counters, Space-Saving overcounts by at most epsilon times the total count
+	candidates, err := NewSpaceSaving(int(math.Ceil(1 / epsilon)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &heavyHitters{candidates: candidates, counts: counts}, nil
+}
+
+func (h *heavyHitters) add(value string) {
+	h.candidates.Add(value, 1)
+	h.counts.Add(value, 1)
+}
+
+// topNRecords returns the top n values and counts, in the same records format as the CombinedLogAnalyzer.
+func (h *heavyHitters) topNRecords(colName string, n int) [][]string {
+	records := [][]string{{colName, colName + "_COUNT"}}
+	for _, c := range h.candidates.Top(n) {
+		count := min(c.Count, h.counts.Estimate(c.Value))
+		records = append(records, []string{c.Value, fmt.Sprintf("%f", float64(count))})
+	}
+
+	return records
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ApproximateLogAnalyzer_GetLogAnalysis(t *testing.T) {
+	urls, _ := zipfValues(50000, 5000)
+
+	// 2000 distinct IPs, with every fourth request an error
+	logEntries := make([]LogEntry, len(urls))
+	for i, url := range urls {
+		logEntries[i] = LogEntry{IP: fmt.Sprintf("10.0.%d.%d", i%2000/256, i%256), URL: url, StatusCode: 200, Size: 10}
+		if i%4 == 3 {
+			logEntries[i].StatusCode = 404
+		}
+	}
+
+	exact, err := (&CombinedLogAnalyzer{}).GetLogAnalysis(logEntries, 3)
+	assert.NoError(t, err)
+
+	approximate, err := (&ApproximateLogAnalyzer{}).GetLogAnalysis(logEntries, 3)
+	assert.NoError(t, err)
+
+	// exact counters are unaffected by approximation
+	assert.Equal(t, exact.RequestCount, approximate.RequestCount)
+	assert.Equal(t, exact.ErrorRate, approximate.ErrorRate)
+	assert.Equal(t, exact.TotalBytes, approximate.TotalBytes)
+
+	// the unique count is within 4 standard errors
+	bounds := approximate.Approximation
+	assert.InDelta(t, exact.UniqueIPCount, approximate.UniqueIPCount, 4*bounds.UniqueCountError*float64(exact.UniqueIPCount))
+
+	// the top URLs match, with counts within the error bound
+	assert.Equal(t, 50, bounds.CountErrorBound)
+	assert.Equal(t, 0.99, bounds.Confidence)
+	assert.Equal(t, exact.TopNMostVisitedURLs[0], approximate.TopNMostVisitedURLs[0])
+	for i := 1; i < len(exact.TopNMostVisitedURLs); i++ {
+		assert.Equal(t, exact.TopNMostVisitedURLs[i][0], approximate.TopNMostVisitedURLs[i][0])
+
+		exactCount, _ := ParseInt(exact.TopNMostVisitedURLs[i][1])
+		approximateCount, _ := ParseInt(approximate.TopNMostVisitedURLs[i][1])
+		assert.GreaterOrEqual(t, approximateCount, exactCount)
+		assert.LessOrEqual(t, approximateCount-exactCount, bounds.CountErrorBound)
+	}
+}
+
+func Test_ApproximateLogAnalyzer_GetLogAnalysis_options(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "http://example.net/faq/"},
+		{IP: "168.41.191.41", URL: "/faq/"},
+		{IP: "177.71.128.21", URL: "/"},
+	}
+
+	normaliser, err := NewURLNormaliser(URLNormaliserConfig{StripHost: true})
+	assert.NoError(t, err)
+
+	l := &ApproximateLogAnalyzer{URLNormaliser: normaliser, IPv4PrefixLen: 24}
+	got, err := l.GetLogAnalysis(logEntries, 5)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, got.UniqueIPCount)
+	assert.Equal(t, [][]string{{"URL", "URL_COUNT"}, {"/faq/", "2.000000"}, {"/", "1.000000"}}, got.TopNMostVisitedURLs)
+	assert.Equal(t, [][]string{{"IPPrefix", "IPPrefix_COUNT"}, {"168.41.191.0/24", "2.000000"}, {"177.71.128.0/24", "1.000000"}}, got.TopNMostActiveIPs)
+
+	_, err = l.GetLogAnalysis(nil, 5)
+	assert.Error(t, err)
+
+	_, err = (&ApproximateLogAnalyzer{Precision: 30}).GetLogAnalysis(logEntries, 5)
+	assert.Error(t, err)
+}
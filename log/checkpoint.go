@@ -0,0 +1,169 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Checkpoint records how far a log file has been read, so that a later read can resume from the same point.
+type Checkpoint struct {
+	// Inode identifies the file, so that a rotated log is read from the start. It is 0 on platforms without inodes.
+	Inode uint64
+	// Offset is the position after the last complete line read
+	Offset int64
+	// LastLineOffset and LastLineHash identify the last line read, so that a truncated or rewritten log is
+	// read from the start
+	LastLineOffset int64
+	LastLineHash   uint64
+	// LineCount is the number of lines before Offset, so that the lines read later can be numbered from the
+	// start of the file
+	LineCount int
+}
+
+// IncrementalFileReader reads the lines of a log file added since its checkpoint, and moves the checkpoint
+// to the end of the lines read. A trailing line without a newline is left to be read once it is complete.
+type IncrementalFileReader struct {
+	LogFilePath string
+	// Checkpoint is where reading resumes from, the whole file is read when nil
+	Checkpoint *Checkpoint
+	// Restarted is set by ReadLines when the file no longer matches the checkpoint, and was read from the start
+	Restarted bool
+}
+
+// ReadLines reads the lines added to the file since the checkpoint. The whole file is read when the file has
+// been rotated or truncated since the checkpoint.
+func (r *IncrementalFileReader) ReadLines() ([]string, error) {
+	file, err := os.Open(r.LogFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{Inode: fileInode(info)}
+	r.Restarted = r.Checkpoint != nil && !r.resumable(file, info)
+	if r.Checkpoint != nil && !r.Restarted {
+		checkpoint = r.Checkpoint
+	}
+
+	if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		checkpoint = &Checkpoint{
+			Inode:          checkpoint.Inode,
+			Offset:         checkpoint.Offset + int64(len(line)),
+			LastLineOffset: checkpoint.Offset,
+			LastLineHash:   hashString(line),
+			LineCount:      checkpoint.LineCount + 1,
+		}
+	}
+
+	r.Checkpoint = checkpoint
+
+	return lines, nil
+}
+
+// resumable checks that the file is the same file, with the same last line, as when the checkpoint was made.
+func (r *IncrementalFileReader) resumable(file *os.File, info os.FileInfo) bool {
+	c := r.Checkpoint
+	if c.Inode != fileInode(info) || c.Offset > info.Size() || c.LastLineOffset > c.Offset {
+		return false
+	}
+
+	lastLine := make([]byte, c.Offset-c.LastLineOffset)
+	if _, err := file.ReadAt(lastLine, c.LastLineOffset); err != nil {
+		return false
+	}
+
+	return hashString(string(lastLine)) == c.LastLineHash
+}
+
+// IncrementalState is the state kept between incremental analyses of a log file: the checkpoint of the file,
+// and the aggregates of the log entries read so far, which record the config they were aggregated with.
+type IncrementalState struct {
+	LogFilePath string
+	// Filter is the filter expression applied to the aggregated log entries
+	Filter     string
+	Checkpoint *Checkpoint
+	Snapshot   *Snapshot
+}
+
+// LoadIncrementalState reads the state of an incremental analysis from a file, returning nil when the file
+// does not exist yet.
+func LoadIncrementalState(path string) (*IncrementalState, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading incremental state: %w", err)
+	}
+	defer gz.Close()
+
+	var state IncrementalState
+	if err := json.NewDecoder(gz).Decode(&state); err != nil {
+		return nil, fmt.Errorf("error decoding incremental state: %w", err)
+	}
+
+	if state.Snapshot == nil || state.Snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported incremental state, delete %s to start again", path)
+	}
+
+	return &state, nil
+}
+
+// SaveIncrementalState writes the state of an incremental analysis to a file, replacing the previous state
+// only once the new state is completely written.
+func SaveIncrementalState(path string, state *IncrementalState) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(file)
+	if err := json.NewEncoder(gz).Encode(state); err != nil {
+		file.Close()
+		return fmt.Errorf("error encoding incremental state: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IncrementalFileReader_ReadLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		initial       string
+		update        func(path string) error
+		wantLines     []string
+		wantRestarted bool
+		wantLineCount int
+	}{
+		{
+			name:    "appended lines",
+			initial: "line 1\nline 2\n",
+			update: func(path string) error {
+				return appendFile(path, "line 3\r\nline 4\n")
+			},
+			wantLines:     []string{"line 3", "line 4"},
+			wantLineCount: 4,
+		},
+		{
+			name:          "no new lines",
+			initial:       "line 1\nline 2\n",
+			update:        func(path string) error { return nil },
+			wantLines:     []string{},
+			wantLineCount: 2,
+		},
+		{
+			name:    "incomplete line is read once complete",
+			initial: "line 1\nline",
+			update: func(path string) error {
+				return appendFile(path, " 2\n")
+			},
+			wantLines:     []string{"line 2"},
+			wantLineCount: 2,
+		},
+		{
+			name:    "truncated",
+			initial: "line 1\nline 2\n",
+			update: func(path string) error {
+				return os.WriteFile(path, []byte("line 3\n"), 0644)
+			},
+			wantLines:     []string{"line 3"},
+			wantRestarted: true,
+			wantLineCount: 1,
+		},
+		{
+			name:    "rewritten",
+			initial: "line 1\nline 2\n",
+			update: func(path string) error {
+				return os.WriteFile(path, []byte("line 3\nline 4\nline 5\n"), 0644)
+			},
+			wantLines:     []string{"line 3", "line 4", "line 5"},
+			wantRestarted: true,
+			wantLineCount: 3,
+		},
+		{
+			name:    "rotated",
+			initial: "line 1\nline 2\n",
+			update: func(path string) error {
+				if err := os.Rename(path, path+".1"); err != nil {
+					return err
+				}
+				// the new log starts with the same lines, so only the inode distinguishes it
+				return os.WriteFile(path, []byte("line 1\nline 2\nline 3\n"), 0644)
+			},
+			wantLines:     []string{"line 1", "line 2", "line 3"},
+			wantRestarted: true,
+			wantLineCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.initial), 0644))
+
+			first := &IncrementalFileReader{LogFilePath: path}
+			_, err := first.ReadLines()
+			assert.NoError(t, err)
+			assert.False(t, first.Restarted)
+
+			assert.NoError(t, tt.update(path))
+
+			second := &IncrementalFileReader{LogFilePath: path, Checkpoint: first.Checkpoint}
+			lines, err := second.ReadLines()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantLines, lines)
+			assert.Equal(t, tt.wantRestarted, second.Restarted)
+			assert.Equal(t, tt.wantLineCount, second.Checkpoint.LineCount)
+		})
+	}
+}
+
+func Test_LoadIncrementalState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	state, err := LoadIncrementalState(path)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+
+	want := &IncrementalState{
+		LogFilePath: "access.log",
+		Filter:      "Method == GET",
+		Checkpoint:  &Checkpoint{Inode: 42, Offset: 14, LastLineOffset: 7, LastLineHash: hashString("line 2\n"), LineCount: 2},
+		Snapshot:    (&SnapshotAnalyzer{}).NewSnapshot([]LogEntry{{IP: "168.41.191.40", URL: "/faq/", StatusCode: 200}}),
+	}
+	assert.NoError(t, SaveIncrementalState(path, want))
+
+	state, err = LoadIncrementalState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, want, state)
+}
+
+func appendFile(path string, data string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
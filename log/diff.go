@@ -0,0 +1,136 @@
+package log
+
+import (
+	"math"
+)
+
+// DefaultSignificantChange is the percentage change at which a movement between two analyses is significant.
+const DefaultSignificantChange = 20.0
+
+// AnalysisDiff is the difference between a baseline and current LogAnalysis.
+type AnalysisDiff struct {
+	Requests  MetricDelta
+	UniqueIPs MetricDelta
+	ErrorRate MetricDelta
+	Bandwidth MetricDelta
+	URLs      []RankChange
+	IPs       []RankChange
+}
+
+// MetricDelta is the change in a metric between the baseline and current periods.
+type MetricDelta struct {
+	Baseline float64
+	Current  float64
+	// PercentChange is nil when the baseline is zero, as the change cannot be expressed as a percentage
+	PercentChange *float64
+	Significant   bool
+}
+
+type RankStatus string
+
+const (
+	RankNew       RankStatus = "new"
+	RankGone      RankStatus = "gone"
+	RankUp        RankStatus = "up"
+	RankDown      RankStatus = "down"
+	RankUnchanged RankStatus = "unchanged"
+)
+
+// RankChange is the movement of a value, such as a URL, in a top N list. A rank of 0 means the value was
+// not in the top N for that period.
+type RankChange struct {
+	Value         string
+	BaselineRank  int
+	CurrentRank   int
+	BaselineCount int
+	CurrentCount  int
+	Status        RankStatus
+}
+
+// CompareAnalyses reports the changes from a baseline analysis to a current analysis. Metrics which change
+// by at least significantChange percent are marked as significant.
+func CompareAnalyses(baseline, current *LogAnalysis, significantChange float64) *AnalysisDiff {
+	if significantChange <= 0 {
+		significantChange = DefaultSignificantChange
+	}
+
+	return &AnalysisDiff{
+		Requests:  newMetricDelta(float64(baseline.RequestCount), float64(current.RequestCount), significantChange),
+		UniqueIPs: newMetricDelta(float64(baseline.UniqueIPCount), float64(current.UniqueIPCount), significantChange),
+		ErrorRate: newMetricDelta(baseline.ErrorRate, current.ErrorRate, significantChange),
+		Bandwidth: newMetricDelta(float64(baseline.TotalBytes), float64(current.TotalBytes), significantChange),
+		URLs:      compareRanks(baseline.TopNMostVisitedURLs, current.TopNMostVisitedURLs),
+		IPs:       compareRanks(baseline.TopNMostActiveIPs, current.TopNMostActiveIPs),
+	}
+}
+
+func newMetricDelta(baseline, current, significantChange float64) MetricDelta {
+	delta := MetricDelta{Baseline: baseline, Current: current}
+
+	if baseline == 0 {
+		delta.Significant = current != 0
+		return delta
+	}
+
+	percentChange := (current - baseline) / baseline * 100
+	delta.PercentChange = &percentChange
+	delta.Significant = math.Abs(percentChange) >= significantChange
+
+	return delta
+}
+
+// compareRanks compares two top N record lists, as returned by a LogAnalyzer. Values in the current list are
+// returned in rank order, followed by values which are no longer in the top N.
+func compareRanks(baseline, current [][]string) []RankChange {
+	baselineRanks := make(map[string]int)
+	baselineCounts := make(map[string]int)
+	for rank, row := range recordRows(baseline) {
+		baselineRanks[row[0]] = rank + 1
+		baselineCounts[row[0]], _ = ParseInt(row[1])
+	}
+
+	changes := make([]RankChange, 0)
+	seen := make(map[string]bool)
+
+	for rank, row := range recordRows(current) {
+		change := RankChange{Value: row[0], CurrentRank: rank + 1}
+		change.CurrentCount, _ = ParseInt(row[1])
+		change.BaselineRank = baselineRanks[row[0]]
+		change.BaselineCount = baselineCounts[row[0]]
+
+		switch {
+		case change.BaselineRank == 0:
+			change.Status = RankNew
+		case change.CurrentRank < change.BaselineRank:
+			change.Status = RankUp
+		case change.CurrentRank > change.BaselineRank:
+			change.Status = RankDown
+		default:
+			change.Status = RankUnchanged
+		}
+
+		changes = append(changes, change)
+		seen[row[0]] = true
+	}
+
+	for rank, row := range recordRows(baseline) {
+		if seen[row[0]] {
+			continue
+		}
+
+		change := RankChange{Value: row[0], BaselineRank: rank + 1, Status: RankGone}
+		change.BaselineCount, _ = ParseInt(row[1])
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// recordRows returns the rows of a records list, without the header row.
+func recordRows(records [][]string) [][]string {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return records[1:]
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CompareAnalyses(t *testing.T) {
+	baseline := &LogAnalysis{
+		RequestCount:        100,
+		ErrorRate:           0.1,
+		TotalBytes:          0,
+		UniqueIPCount:       10,
+		TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "50.000000"}, {"/about", "30.000000"}, {"/old", "20.000000"}},
+		TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "60.000000"}},
+	}
+
+	current := &LogAnalysis{
+		RequestCount:        110,
+		ErrorRate:           0.2,
+		TotalBytes:          1000,
+		UniqueIPCount:       10,
+		TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/about", "60.000000"}, {"/home", "40.000000"}, {"/new", "10.000000"}},
+		TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "70.000000"}},
+	}
+
+	want := &AnalysisDiff{
+		Requests:  MetricDelta{Baseline: 100, Current: 110, PercentChange: ptr(10.0), Significant: false},
+		UniqueIPs: MetricDelta{Baseline: 10, Current: 10, PercentChange: ptr(0.0), Significant: false},
+		ErrorRate: MetricDelta{Baseline: 0.1, Current: 0.2, PercentChange: ptr(100.0), Significant: true},
+		Bandwidth: MetricDelta{Baseline: 0, Current: 1000, PercentChange: nil, Significant: true},
+		URLs: []RankChange{
+			{Value: "/about", BaselineRank: 2, CurrentRank: 1, BaselineCount: 30, CurrentCount: 60, Status: RankUp},
+			{Value: "/home", BaselineRank: 1, CurrentRank: 2, BaselineCount: 50, CurrentCount: 40, Status: RankDown},
+			{Value: "/new", BaselineRank: 0, CurrentRank: 3, BaselineCount: 0, CurrentCount: 10, Status: RankNew},
+			{Value: "/old", BaselineRank: 3, CurrentRank: 0, BaselineCount: 20, CurrentCount: 0, Status: RankGone},
+		},
+		IPs: []RankChange{
+			{Value: "192.168.0.1", BaselineRank: 1, CurrentRank: 1, BaselineCount: 60, CurrentCount: 70, Status: RankUnchanged},
+		},
+	}
+
+	got := CompareAnalyses(baseline, current, 20)
+	assert.InDelta(t, *want.Requests.PercentChange, *got.Requests.PercentChange, 1e-9)
+	assert.InDelta(t, *want.ErrorRate.PercentChange, *got.ErrorRate.PercentChange, 1e-9)
+
+	// compare the remaining fields exactly, with the floating point percentages checked above
+	got.Requests.PercentChange, want.Requests.PercentChange = nil, nil
+	got.ErrorRate.PercentChange, want.ErrorRate.PercentChange = nil, nil
+	assert.Equal(t, want, got)
+}
+
+func Test_newMetricDelta(t *testing.T) {
+	tests := []struct {
+		name              string
+		baseline          float64
+		current           float64
+		significantChange float64
+		wantPercent       *float64
+		wantSignificant   bool
+	}{
+		{name: "increase below threshold", baseline: 100, current: 110, significantChange: 20, wantPercent: ptr(10.0), wantSignificant: false},
+		{name: "increase at threshold", baseline: 100, current: 120, significantChange: 20, wantPercent: ptr(20.0), wantSignificant: true},
+		{name: "decrease above threshold", baseline: 100, current: 50, significantChange: 20, wantPercent: ptr(-50.0), wantSignificant: true},
+		{name: "zero baseline and current", baseline: 0, current: 0, significantChange: 20, wantPercent: nil, wantSignificant: false},
+		{name: "zero baseline", baseline: 0, current: 5, significantChange: 20, wantPercent: nil, wantSignificant: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newMetricDelta(tt.baseline, tt.current, tt.significantChange)
+			assert.Equal(t, tt.wantPercent, got.PercentChange)
+			assert.Equal(t, tt.wantSignificant, got.Significant)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,464 @@
+package log
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+type LogFilter interface {
+	Match(LogEntry) bool
+	FilterLogEntries([]LogEntry) []LogEntry
+}
+
+// ExpressionFilter filters log entries using a boolean expression over LogEntry fields, e.g.
+//
+//	Method == GET and StatusCode == 200 and IP in 168.41.191.0/24 and not UserAgent =~ "(?i)bot"
+//
+// Supported operators are ==, !=, <, <=, >, >= (numeric fields only for ordering), =~ and !~ (regex match),
+// in (CIDR membership), combined with and/or/not (or &&/||/!) and parentheses. Extra fields are compared by
+// name, e.g. Extra.vhost == example.com.
+// Values are bare words or quoted strings, where \" and \\ are the only escape sequences.
+type ExpressionFilter struct {
+	Expression string
+	root       filterNode
+}
+
+// FilterError reports a filter expression that could not be compiled, and the position of the mistake.
+type FilterError struct {
+	Expression string
+	Pos        int // 1-based position of the mistake in the expression
+	Msg        string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s\n  %s\n  %s^", e.Pos, e.Msg, e.Expression, strings.Repeat(" ", e.Pos-1))
+}
+
+// NewExpressionFilter compiles a filter expression. An empty expression matches every log entry.
+func NewExpressionFilter(expression string) (*ExpressionFilter, error) {
+	f := &ExpressionFilter{Expression: expression}
+	if strings.TrimSpace(expression) == "" {
+		return f, nil
+	}
+
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{expression: expression, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorAt(tok, "unexpected %q, expected and/or", tok.text)
+	}
+
+	f.root = root
+
+	return f, nil
+}
+
+// Match reports whether a log entry satisfies the filter expression.
+func (f *ExpressionFilter) Match(entry LogEntry) bool {
+	if f.root == nil {
+		return true
+	}
+
+	return f.root.match(entry)
+}
+
+// FilterLogEntries returns the log entries that satisfy the filter expression.
+func (f *ExpressionFilter) FilterLogEntries(logEntries []LogEntry) []LogEntry {
+	if f.root == nil {
+		return logEntries
+	}
+
+	filtered := make([]LogEntry, 0, len(logEntries))
+	for _, entry := range logEntries {
+		if f.root.match(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	return filtered
+}
+
+// filterField describes a LogEntry field which can be referenced in a filter expression.
+type filterField struct {
+	name    string
+	numeric bool
+	str     func(LogEntry) string
+	num     func(LogEntry) int
+}
+
+// filterFields are the filterable LogEntry fields, keyed by lower case field name.
+var filterFields = map[string]filterField{
+	"ip":         {name: "IP", str: func(e LogEntry) string { return e.IP }},
+	"identity":   {name: "Identity", str: func(e LogEntry) string { return e.Identity }},
+	"userid":     {name: "UserID", str: func(e LogEntry) string { return e.UserID }},
+	"time":       {name: "Time", str: func(e LogEntry) string { return e.Time }},
+	"method":     {name: "Method", str: func(e LogEntry) string { return e.Method }},
+	"url":        {name: "URL", str: func(e LogEntry) string { return e.URL }},
+	"protocol":   {name: "Protocol", str: func(e LogEntry) string { return e.Protocol }},
+	"statuscode": {name: "StatusCode", numeric: true, num: func(e LogEntry) int { return e.StatusCode }},
+	"size":       {name: "Size", numeric: true, num: func(e LogEntry) int { return e.Size }},
+	"referrer":   {name: "Referrer", str: func(e LogEntry) string { return e.Referrer }},
+	"useragent":  {name: "UserAgent", str: func(e LogEntry) string { return e.UserAgent }},
+	"country":    {name: "Country", str: func(e LogEntry) string { return e.Country }},
+	"asn":        {name: "ASN", numeric: true, num: func(e LogEntry) int { return e.ASN }},
+	"asorg":      {name: "ASOrg", str: func(e LogEntry) string { return e.ASOrg }},
+	// ResponseTime is in milliseconds, and -1 for log entries without a response time
+	"responsetime": {name: "ResponseTime", numeric: true, num: responseTimeMillis},
+}
+
+// extraFieldPrefix prefixes the names of extra fields in filters, e.g. Extra.vhost.
+const extraFieldPrefix = "extra."
+
+// lookupFilterField returns the filterable field of a name. Extra fields are looked up by their configured name,
+// and are empty for log entries without them.
+func lookupFilterField(name string) (filterField, bool) {
+	if len(name) > len(extraFieldPrefix) && strings.EqualFold(name[:len(extraFieldPrefix)], extraFieldPrefix) {
+		key := name[len(extraFieldPrefix):]
+		return filterField{name: name, str: func(e LogEntry) string { return e.Extra[key] }}, true
+	}
+
+	field, ok := filterFields[strings.ToLower(name)]
+
+	return field, ok
+}
+
+func responseTimeMillis(e LogEntry) int {
+	if e.ResponseTime == nil {
+		return -1
+	}
+
+	return int(e.ResponseTime.Milliseconds())
+}
+
+type filterNode interface {
+	match(LogEntry) bool
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n *andNode) match(e LogEntry) bool { return n.left.match(e) && n.right.match(e) }
+
+type orNode struct{ left, right filterNode }
+
+func (n *orNode) match(e LogEntry) bool { return n.left.match(e) || n.right.match(e) }
+
+type notNode struct{ node filterNode }
+
+func (n *notNode) match(e LogEntry) bool { return !n.node.match(e) }
+
+type compareNode struct {
+	field filterField
+	op    string
+	str   string
+	num   int
+}
+
+func (n *compareNode) match(e LogEntry) bool {
+	if !n.field.numeric {
+		equal := n.field.str(e) == n.str
+		if n.op == "!=" {
+			return !equal
+		}
+		return equal
+	}
+
+	v := n.field.num(e)
+	switch n.op {
+	case "==":
+		return v == n.num
+	case "!=":
+		return v != n.num
+	case "<":
+		return v < n.num
+	case "<=":
+		return v <= n.num
+	case ">":
+		return v > n.num
+	default:
+		return v >= n.num
+	}
+}
+
+type regexNode struct {
+	field  filterField
+	regex  *regexp.Regexp
+	negate bool
+}
+
+func (n *regexNode) match(e LogEntry) bool {
+	return n.regex.MatchString(n.field.str(e)) != n.negate
+}
+
+type cidrNode struct {
+	field  filterField
+	prefix netip.Prefix
+}
+
+func (n *cidrNode) match(e LogEntry) bool {
+	addr, err := netip.ParseAddr(n.field.str(e))
+	if err != nil {
+		return false
+	}
+
+	return n.prefix.Contains(addr.Unmap())
+}
+
+type filterTokenKind int
+
+const (
+	tokenEOF filterTokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int // 0-based byte offset in the expression
+}
+
+// filterOperators are the comparison and boolean operators, longest first so that "<=" is not lexed as "<".
+var filterOperators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!"}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: tokenLParen, text: "(", pos: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: tokenRParen, text: ")", pos: i})
+			i++
+
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			closed := false
+			for i++; i < len(expression); i++ {
+				if expression[i] == '\\' && i+1 < len(expression) && (expression[i+1] == c || expression[i+1] == '\\') {
+					i++
+				} else if expression[i] == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(expression[i])
+			}
+			if !closed {
+				return nil, &FilterError{Expression: expression, Pos: start + 1, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: sb.String(), pos: start})
+
+		default:
+			if op := matchFilterOperator(expression[i:]); op != "" {
+				tokens = append(tokens, filterToken{kind: tokenOperator, text: op, pos: i})
+				i += len(op)
+				continue
+			}
+
+			start := i
+			for i < len(expression) && !strings.ContainsRune(" \t\r\n()\"'=!<>&|", rune(expression[i])) {
+				i++
+			}
+			if start == i {
+				return nil, &FilterError{Expression: expression, Pos: start + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: expression[start:i], pos: start})
+		}
+	}
+
+	return append(tokens, filterToken{kind: tokenEOF, pos: len(expression)}), nil
+}
+
+func matchFilterOperator(s string) string {
+	for _, op := range filterOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// filterParser is a recursive descent parser for filter expressions, with the grammar:
+//
+//	or         = and { ("or" | "||") and }
+//	and        = unary { ("and" | "&&") unary }
+//	unary      = ("not" | "!") unary | "(" or ")" | comparison
+//	comparison = field operator value | field "in" value
+type filterParser struct {
+	expression string
+	tokens     []filterToken
+	pos        int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) errorAt(tok filterToken, format string, args ...any) error {
+	return &FilterError{Expression: p.expression, Pos: tok.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isKeyword reports whether a token is the given boolean keyword or its symbolic equivalent.
+func isKeyword(tok filterToken, keyword, symbol string) bool {
+	return (tok.kind == tokenWord && strings.EqualFold(tok.text, keyword)) ||
+		(tok.kind == tokenOperator && tok.text == symbol)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for isKeyword(p.peek(), "or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for isKeyword(p.peek(), "and", "&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	tok := p.peek()
+
+	if isKeyword(tok, "not", "!") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: node}, nil
+	}
+
+	if tok.kind == tokenLParen {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorAt(closing, "expected \")\" to close \"(\" at position %d", tok.pos+1)
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != tokenWord {
+		return nil, p.errorAt(fieldTok, "expected field name, got %s", describeFilterToken(fieldTok))
+	}
+
+	field, ok := lookupFilterField(fieldTok.text)
+	if !ok {
+		return nil, p.errorAt(fieldTok, "unknown field %q", fieldTok.text)
+	}
+
+	opTok := p.next()
+	op := opTok.text
+	isIn := opTok.kind == tokenWord && strings.EqualFold(op, "in")
+	if !isIn && (opTok.kind != tokenOperator || op == "&&" || op == "||" || op == "!") {
+		return nil, p.errorAt(opTok, "expected operator after field %s, got %s", field.name, describeFilterToken(opTok))
+	}
+
+	valueTok := p.next()
+	if valueTok.kind != tokenWord && valueTok.kind != tokenString {
+		return nil, p.errorAt(valueTok, "expected value after %q, got %s", op, describeFilterToken(valueTok))
+	}
+
+	switch {
+	case isIn:
+		if field.numeric {
+			return nil, p.errorAt(opTok, "operator \"in\" is not supported for numeric field %s", field.name)
+		}
+		prefix, err := netip.ParsePrefix(valueTok.text)
+		if err != nil {
+			return nil, p.errorAt(valueTok, "invalid CIDR %q", valueTok.text)
+		}
+		return &cidrNode{field: field, prefix: prefix.Masked()}, nil
+
+	case op == "=~" || op == "!~":
+		if field.numeric {
+			return nil, p.errorAt(opTok, "operator %q is not supported for numeric field %s", op, field.name)
+		}
+		regex, err := regexp.Compile(valueTok.text)
+		if err != nil {
+			return nil, p.errorAt(valueTok, "invalid regex: %v", err)
+		}
+		return &regexNode{field: field, regex: regex, negate: op == "!~"}, nil
+
+	case field.numeric:
+		num, err := ParseInt(valueTok.text)
+		if err != nil {
+			return nil, p.errorAt(valueTok, "expected number for field %s, got %q", field.name, valueTok.text)
+		}
+		return &compareNode{field: field, op: op, num: num}, nil
+
+	default:
+		if op != "==" && op != "!=" {
+			return nil, p.errorAt(opTok, "operator %q is only supported for numeric fields", op)
+		}
+		return &compareNode{field: field, op: op, str: valueTok.text}, nil
+	}
+}
+
+func describeFilterToken(tok filterToken) string {
+	if tok.kind == tokenEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
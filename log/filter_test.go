@@ -0,0 +1,136 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExpressionFilter_Match(t *testing.T) {
+	entry := LogEntry{
+		IP:         "168.41.191.40",
+		Identity:   "-",
+		UserID:     "admin",
+		Method:     "GET",
+		URL:        "/docs/manage-websites/",
+		Protocol:   "HTTP/1.1",
+		StatusCode: 200,
+		Size:       3574,
+		Referrer:   "-",
+		UserAgent:  "Mozilla/5.0 (compatible; MSIE 10.6; Windows NT 6.1; Trident/5.0)",
+		Extra:      map[string]string{"vhost": "example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{name: "empty expression matches everything", expression: "", want: true},
+		{name: "string equality", expression: "Method == GET", want: true},
+		{name: "string inequality", expression: `Method != "GET"`, want: false},
+		{name: "field names are case insensitive", expression: "method == GET", want: true},
+		{name: "numeric equality", expression: "StatusCode == 200", want: true},
+		{name: "numeric ordering", expression: "StatusCode >= 400", want: false},
+		{name: "numeric less than", expression: "Size < 4000", want: true},
+		{name: "missing response time", expression: "ResponseTime < 0", want: true},
+		{name: "extra field", expression: "extra.vhost == example.com", want: true},
+		{name: "missing extra field is empty", expression: `Extra.upstream == ""`, want: true},
+		{name: "regex match", expression: `URL =~ "^/docs/"`, want: true},
+		{name: "regex not match", expression: `UserAgent !~ "(?i)bot"`, want: true},
+		{name: "regex with escaped characters", expression: `UserAgent =~ "MSIE \d+\.\d"`, want: true},
+		{name: "CIDR membership", expression: "IP in 168.41.191.0/24", want: true},
+		{name: "CIDR non membership", expression: `IP in "10.0.0.0/8"`, want: false},
+		{name: "and", expression: "Method == GET and StatusCode == 404", want: false},
+		{name: "or", expression: "Method == POST or StatusCode == 200", want: true},
+		{name: "not", expression: "not UserID == admin", want: false},
+		{name: "symbolic operators", expression: "!(Method == POST) && (UserID == admin || UserID == bob)", want: true},
+		{name: "and binds tighter than or", expression: "Method == POST and StatusCode == 404 or UserID == admin", want: true},
+		{name: "parentheses override precedence", expression: "Method == POST and (StatusCode == 404 or UserID == admin)", want: false},
+		{
+			name:       "full example",
+			expression: `Method == GET and StatusCode == 200 and IP in 168.41.191.0/24 and not UserAgent =~ "(?i)bot"`,
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewExpressionFilter(tt.expression)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, f.Match(entry))
+		})
+	}
+}
+
+func Test_NewExpressionFilter_errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantPos    int
+		wantMsg    string
+	}{
+		{name: "unknown field", expression: "StatusCode == 200 and Stauts == 1", wantPos: 23, wantMsg: `unknown field "Stauts"`},
+		{name: "missing operator", expression: "Method GET", wantPos: 8, wantMsg: `expected operator after field Method, got "GET"`},
+		{name: "missing value", expression: "Method ==", wantPos: 10, wantMsg: `expected value after "==", got end of filter`},
+		{name: "non numeric value", expression: "StatusCode == ok", wantPos: 15, wantMsg: `expected number for field StatusCode, got "ok"`},
+		{name: "ordering on string field", expression: "URL > /a", wantPos: 5, wantMsg: `operator ">" is only supported for numeric fields`},
+		{name: "invalid regex", expression: `URL =~ "("`, wantPos: 8},
+		{name: "invalid CIDR", expression: "IP in 10.0.0.0/99", wantPos: 7, wantMsg: `invalid CIDR "10.0.0.0/99"`},
+		{name: "unterminated string", expression: `URL == "/a`, wantPos: 8, wantMsg: "unterminated string"},
+		{name: "unclosed parenthesis", expression: "(Method == GET", wantPos: 15},
+		{name: "trailing tokens", expression: "Method == GET Size == 1", wantPos: 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpressionFilter(tt.expression)
+			var filterErr *FilterError
+			if assert.ErrorAs(t, err, &filterErr) {
+				assert.Equal(t, tt.wantPos, filterErr.Pos)
+				if tt.wantMsg != "" {
+					assert.Equal(t, tt.wantMsg, filterErr.Msg)
+				}
+			}
+		})
+	}
+}
+
+func Test_ExpressionFilter_FilterLogEntries(t *testing.T) {
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40", Method: "GET", StatusCode: 200},
+		{IP: "168.41.191.41", Method: "GET", StatusCode: 404},
+		{IP: "177.71.128.21", Method: "POST", StatusCode: 200},
+		{IP: "50.112.00.11", Method: "GET", StatusCode: 200},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       []LogEntry
+	}{
+		{
+			name:       "empty filter returns all entries",
+			expression: "",
+			want:       logEntries,
+		},
+		{
+			name:       "filter by method and status",
+			expression: "Method == GET and StatusCode == 200",
+			want:       []LogEntry{logEntries[0], logEntries[3]},
+		},
+		{
+			name:       "invalid IPs are never in a CIDR",
+			expression: "IP in 50.112.0.0/16",
+			want:       []LogEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewExpressionFilter(tt.expression)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, f.FilterLogEntries(logEntries))
+		})
+	}
+}
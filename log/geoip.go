@@ -0,0 +1,301 @@
+package log
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type LogEnricher interface {
+	EnrichLogEntries([]LogEntry) []LogEntry
+}
+
+// GeoIPRecord is the location and network of an IP address.
+type GeoIPRecord struct {
+	Country string // ISO 3166-1 alpha-2 country code
+	ASN     int    // autonomous system number
+	ASOrg   string // autonomous system organisation
+}
+
+// IPDatabase looks up the GeoIPRecord for an IP address in a local database.
+type IPDatabase interface {
+	Lookup(netip.Addr) (GeoIPRecord, bool)
+	Close() error
+}
+
+// GeoIPEnricher annotates log entries with the country and network of their IP, using local databases
+// so no IP addresses leave the machine. Databases are searched in order, with the first database to
+// provide a field taking precedence. Log entries are left unchanged when there are no databases.
+type GeoIPEnricher struct {
+	Databases []IPDatabase
+}
+
+// EnrichLogEntries returns a copy of the log entries annotated with their GeoIPRecord.
+// Fields which cannot be found in any database are set to "-".
+func (e *GeoIPEnricher) EnrichLogEntries(logEntries []LogEntry) []LogEntry {
+	if len(e.Databases) == 0 {
+		return logEntries
+	}
+
+	enriched := make([]LogEntry, len(logEntries))
+	for i, entry := range logEntries {
+		record := e.lookup(entry.IP)
+
+		entry.Country, entry.ASOrg = "-", "-"
+		if record.Country != "" {
+			entry.Country = record.Country
+		}
+		if record.ASOrg != "" {
+			entry.ASOrg = record.ASOrg
+		}
+		entry.ASN = record.ASN
+
+		enriched[i] = entry
+	}
+
+	return enriched
+}
+
+// Close closes the databases, returning the first error.
+func (e *GeoIPEnricher) Close() error {
+	var firstErr error
+	for _, db := range e.Databases {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (e *GeoIPEnricher) lookup(ip string) GeoIPRecord {
+	var record GeoIPRecord
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return record
+	}
+
+	for _, db := range e.Databases {
+		found, ok := db.Lookup(addr.Unmap())
+		if !ok {
+			continue
+		}
+
+		if record.Country == "" {
+			record.Country = found.Country
+		}
+		if record.ASN == 0 {
+			record.ASN, record.ASOrg = found.ASN, found.ASOrg
+		}
+	}
+
+	return record
+}
+
+// OpenIPDatabase opens a MaxMind format database (.mmdb), or a CSV range database (.csv).
+func OpenIPDatabase(path string) (IPDatabase, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening mmdb database %s: %w", path, err)
+		}
+		return &MMDBDatabase{reader: reader}, nil
+	case ".csv":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening csv database %s: %w", path, err)
+		}
+		defer file.Close()
+
+		db, err := LoadCSVDatabase(file)
+		if err != nil {
+			return nil, fmt.Errorf("error loading csv database %s: %w", path, err)
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown IP database format: %s, expected .mmdb or .csv", path)
+	}
+}
+
+// MMDBDatabase is a MaxMind format database, e.g. GeoLite2-Country or GeoLite2-ASN.
+type MMDBDatabase struct {
+	reader *maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN   int    `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+func (d *MMDBDatabase) Lookup(addr netip.Addr) (GeoIPRecord, bool) {
+	var record mmdbRecord
+	network, ok, err := d.reader.LookupNetwork(net.IP(addr.AsSlice()), &record)
+	if err != nil || !ok || network == nil {
+		return GeoIPRecord{}, false
+	}
+
+	return GeoIPRecord{Country: record.Country.ISOCode, ASN: record.ASN, ASOrg: record.ASOrg}, true
+}
+
+// Close unmaps the database file.
+func (d *MMDBDatabase) Close() error {
+	return d.reader.Close()
+}
+
+// CSVDatabase is an IP range database loaded from CSV. The header row names the columns, which are either
+// start_ip and end_ip for inclusive address ranges, or network for CIDR ranges, along with the optional
+// columns country, asn and as_org.
+type CSVDatabase struct {
+	ranges []ipRange // sorted by start address, then by end address descending so nested ranges follow
+	// maxEnds are the highest end address of the ranges up to and including each range, to stop searching
+	// for containing ranges early
+	maxEnds []netip.Addr
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	record     GeoIPRecord
+}
+
+// LoadCSVDatabase loads a CSV range database.
+func LoadCSVDatabase(r io.Reader) (*CSVDatabase, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	_, hasNetwork := columns["network"]
+	_, hasStart := columns["start_ip"]
+	_, hasEnd := columns["end_ip"]
+	if !hasNetwork && !(hasStart && hasEnd) {
+		return nil, fmt.Errorf("header must contain network, or start_ip and end_ip columns")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	db := &CSVDatabase{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var ipr ipRange
+		if hasNetwork {
+			prefix, err := netip.ParsePrefix(field(row, "network"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid network: %w", line, err)
+			}
+			ipr.start, ipr.end = prefixRange(prefix)
+		} else {
+			if ipr.start, err = netip.ParseAddr(field(row, "start_ip")); err != nil {
+				return nil, fmt.Errorf("line %d: invalid start_ip: %w", line, err)
+			}
+			if ipr.end, err = netip.ParseAddr(field(row, "end_ip")); err != nil {
+				return nil, fmt.Errorf("line %d: invalid end_ip: %w", line, err)
+			}
+			ipr.start, ipr.end = ipr.start.Unmap(), ipr.end.Unmap()
+		}
+
+		ipr.record.Country = field(row, "country")
+		ipr.record.ASOrg = field(row, "as_org")
+		if asn := strings.TrimPrefix(strings.ToUpper(field(row, "asn")), "AS"); asn != "" {
+			if ipr.record.ASN, err = strconv.Atoi(asn); err != nil {
+				return nil, fmt.Errorf("line %d: invalid asn: %w", line, err)
+			}
+		}
+
+		db.ranges = append(db.ranges, ipr)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		if c := db.ranges[i].start.Compare(db.ranges[j].start); c != 0 {
+			return c < 0
+		}
+		return db.ranges[j].end.Less(db.ranges[i].end)
+	})
+
+	db.maxEnds = make([]netip.Addr, len(db.ranges))
+	for i, ipr := range db.ranges {
+		db.maxEnds[i] = ipr.end
+		if i > 0 && ipr.end.Less(db.maxEnds[i-1]) {
+			db.maxEnds[i] = db.maxEnds[i-1]
+		}
+	}
+
+	return db, nil
+}
+
+// Lookup returns the record of the most specific range containing the address, e.g. a /24 nested in a /16.
+func (d *CSVDatabase) Lookup(addr netip.Addr) (GeoIPRecord, bool) {
+	// find the last range starting at or before the address
+	i := sort.Search(len(d.ranges), func(i int) bool {
+		return addr.Less(d.ranges[i].start)
+	}) - 1
+
+	// the first containing range found searching back has the latest start, and is the most specific. The search
+	// stops once no earlier range ends at or after the address.
+	for ; i >= 0 && !d.maxEnds[i].Less(addr); i-- {
+		if !d.ranges[i].end.Less(addr) {
+			return d.ranges[i].record, true
+		}
+	}
+
+	return GeoIPRecord{}, false
+}
+
+// Close does nothing, as CSV databases are loaded into memory.
+func (d *CSVDatabase) Close() error {
+	return nil
+}
+
+// prefixRange returns the first and last addresses of a prefix.
+func prefixRange(prefix netip.Prefix) (netip.Addr, netip.Addr) {
+	start := prefix.Masked().Addr()
+
+	bytes := start.AsSlice()
+	hostBits := start.BitLen() - prefix.Bits()
+	for i := len(bytes) - 1; i >= 0 && hostBits > 0; i-- {
+		if hostBits >= 8 {
+			bytes[i] = 0xff
+			hostBits -= 8
+		} else {
+			bytes[i] |= byte(1<<hostBits - 1)
+			hostBits = 0
+		}
+	}
+
+	end, _ := netip.AddrFromSlice(bytes)
+
+	return start.Unmap(), end.Unmap()
+}
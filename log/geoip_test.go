@@ -0,0 +1,217 @@
+package log
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OpenIPDatabase_Lookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		ip     string
+		want   GeoIPRecord
+		wantOk bool
+	}{
+		{
+			name:   "mmdb IPv4 lookup",
+			path:   "testdata/geoip.mmdb",
+			ip:     "168.41.191.40",
+			want:   GeoIPRecord{Country: "AU", ASN: 64500, ASOrg: "Example Networks"},
+			wantOk: true,
+		},
+		{
+			name:   "mmdb IPv6 lookup",
+			path:   "testdata/geoip.mmdb",
+			ip:     "2001:db8::1",
+			want:   GeoIPRecord{Country: "NZ", ASN: 64502, ASOrg: "Example IPv6"},
+			wantOk: true,
+		},
+		{
+			name:   "mmdb address not found",
+			path:   "testdata/geoip.mmdb",
+			ip:     "10.0.0.1",
+			wantOk: false,
+		},
+		{
+			name:   "csv IPv4 lookup at start of range",
+			path:   "testdata/geoip.csv",
+			ip:     "177.71.128.0",
+			want:   GeoIPRecord{Country: "BR", ASN: 64501, ASOrg: "Example Telecom"},
+			wantOk: true,
+		},
+		{
+			name:   "csv IPv4 lookup at end of range",
+			path:   "testdata/geoip.csv",
+			ip:     "168.41.191.255",
+			want:   GeoIPRecord{Country: "AU", ASN: 64500, ASOrg: "Example Networks"},
+			wantOk: true,
+		},
+		{
+			name:   "csv IPv6 lookup",
+			path:   "testdata/geoip.csv",
+			ip:     "2001:db8::1",
+			want:   GeoIPRecord{Country: "NZ", ASN: 64502, ASOrg: "Example IPv6"},
+			wantOk: true,
+		},
+		{
+			name:   "csv address between ranges not found",
+			path:   "testdata/geoip.csv",
+			ip:     "169.0.0.1",
+			wantOk: false,
+		},
+		{
+			name:   "csv address before first range not found",
+			path:   "testdata/geoip.csv",
+			ip:     "1.1.1.1",
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := OpenIPDatabase(tt.path)
+			assert.NoError(t, err)
+
+			got, ok := db.Lookup(netip.MustParseAddr(tt.ip))
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, db.Close())
+		})
+	}
+}
+
+func Test_OpenIPDatabase_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "unknown format", path: "testdata/geoip.txt"},
+		{name: "missing mmdb", path: "testdata/missing.mmdb"},
+		{name: "missing csv", path: "testdata/missing.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenIPDatabase(tt.path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_LoadCSVDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		ip      string
+		want    GeoIPRecord
+		wantErr bool
+	}{
+		{
+			name: "network column",
+			csv:  "network,country\n168.41.191.0/24,AU\n",
+			ip:   "168.41.191.40",
+			want: GeoIPRecord{Country: "AU"},
+		},
+		{
+			name: "IPv6 network column",
+			csv:  "network,asn,as_org\n2001:db8::/33,64502,Example IPv6\n",
+			ip:   "2001:db8:7fff::1",
+			want: GeoIPRecord{ASN: 64502, ASOrg: "Example IPv6"},
+		},
+		{
+			name: "most specific nested range",
+			csv:  "network,country\n10.0.0.0/8,AU\n10.1.0.0/16,BR\n10.1.2.0/24,NZ\n10.1.0.0/24,US\n",
+			ip:   "10.1.2.3",
+			want: GeoIPRecord{Country: "NZ"},
+		},
+		{
+			name: "enclosing range after a nested range",
+			csv:  "network,country\n10.0.0.0/8,AU\n10.1.0.0/16,BR\n",
+			ip:   "10.2.0.1",
+			want: GeoIPRecord{Country: "AU"},
+		},
+		{
+			name: "nested range with the same start",
+			csv:  "network,country\n10.1.0.0/24,US\n10.0.0.0/8,AU\n10.1.0.0/16,BR\n",
+			ip:   "10.1.0.1",
+			want: GeoIPRecord{Country: "US"},
+		},
+		{
+			name:    "missing range columns",
+			csv:     "country,asn\nAU,64500\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid network",
+			csv:     "network,country\n168.41.191.0/33,AU\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid asn",
+			csv:     "network,asn\n168.41.191.0/24,ASX\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := LoadCSVDatabase(strings.NewReader(tt.csv))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadCSVDatabase() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				got, ok := db.Lookup(netip.MustParseAddr(tt.ip))
+				assert.True(t, ok)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_GeoIPEnricher_EnrichLogEntries(t *testing.T) {
+	countries, err := LoadCSVDatabase(strings.NewReader("network,country\n168.41.191.0/24,AU\n177.71.128.0/17,BR\n"))
+	assert.NoError(t, err)
+
+	networks, err := OpenIPDatabase("testdata/geoip.mmdb")
+	assert.NoError(t, err)
+
+	logEntries := []LogEntry{
+		{IP: "168.41.191.40"},
+		{IP: "177.71.128.21"},
+		{IP: "10.0.0.1"},
+		{IP: "invalid"},
+	}
+
+	tests := []struct {
+		name     string
+		enricher *GeoIPEnricher
+		want     []LogEntry
+	}{
+		{
+			name:     "no databases leaves entries unchanged",
+			enricher: &GeoIPEnricher{},
+			want:     logEntries,
+		},
+		{
+			name:     "earlier databases take precedence",
+			enricher: &GeoIPEnricher{Databases: []IPDatabase{countries, networks}},
+			want: []LogEntry{
+				{IP: "168.41.191.40", Country: "AU", ASN: 64500, ASOrg: "Example Networks"},
+				{IP: "177.71.128.21", Country: "BR", ASN: 64501, ASOrg: "Example Telecom"},
+				{IP: "10.0.0.1", Country: "-", ASOrg: "-"},
+				{IP: "invalid", Country: "-", ASOrg: "-"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.enricher.EnrichLogEntries(logEntries))
+		})
+	}
+}
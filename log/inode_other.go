@@ -0,0 +1,10 @@
+//go:build !unix
+
+package log
+
+import "os"
+
+// fileInode returns 0 on platforms without inodes, so a rotated log is only detected by its last line.
+func fileInode(os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode of a file.
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+
+	return 0
+}
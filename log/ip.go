@@ -0,0 +1,81 @@
+package log
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// NormaliseIP parses the IP field of a log line with net/netip and returns the canonical form of the client
+// address, along with flags describing anything suspect about the field.
+//
+// The left-most address of an X-Forwarded-For style chain is taken as the client address. IPv4 addresses with
+// leading zero octets (e.g. 79.125.00.21) are normalised by their decimal value. Invalid addresses are returned
+// verbatim.
+func NormaliseIP(field string) (string, []string) {
+	var flags []string
+
+	ip := field
+	if strings.Contains(field, ",") {
+		ip = strings.TrimSpace(strings.Split(field, ",")[0])
+		flags = append(flags, fmt.Sprintf("IP %q is a forwarded chain, using client address %s", field, ip))
+	}
+
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.Unmap().String(), flags
+	}
+
+	if addr, ok := parseLeadingZeroIPv4(ip); ok {
+		flags = append(flags, fmt.Sprintf("IP %q has leading zeros, normalised to %s", ip, addr))
+		return addr.String(), flags
+	}
+
+	flags = append(flags, fmt.Sprintf("invalid IP %q", ip))
+
+	return ip, flags
+}
+
+// parseLeadingZeroIPv4 parses a dotted decimal IPv4 address which netip rejects due to leading zero octets.
+func parseLeadingZeroIPv4(ip string) (netip.Addr, bool) {
+	octets := strings.Split(ip, ".")
+	if len(octets) != 4 {
+		return netip.Addr{}, false
+	}
+
+	var addr [4]byte
+	for i, octet := range octets {
+		n, err := strconv.ParseUint(octet, 10, 8)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr[i] = byte(n)
+	}
+
+	return netip.AddrFrom4(addr), true
+}
+
+// IPPrefix returns the network prefix containing an IP address, e.g. 168.41.191.0/24, for grouping clients by
+// network. A prefix length of 0 leaves addresses of that family ungrouped. Invalid addresses are returned verbatim.
+func IPPrefix(ip string, ipv4PrefixLen, ipv6PrefixLen int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	prefixLen := ipv6PrefixLen
+	if addr.Is4() {
+		prefixLen = ipv4PrefixLen
+	}
+
+	if prefixLen <= 0 {
+		return ip
+	}
+
+	prefix, err := addr.Prefix(prefixLen)
+	if err != nil {
+		return ip
+	}
+
+	return prefix.String()
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NormaliseIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     string
+		want      string
+		wantFlags []string
+	}{
+		{
+			name:  "valid IPv4",
+			field: "177.71.128.21",
+			want:  "177.71.128.21",
+		},
+		{
+			name:      "IPv4 with leading zero octet",
+			field:     "79.125.00.21",
+			want:      "79.125.0.21",
+			wantFlags: []string{`IP "79.125.00.21" has leading zeros, normalised to 79.125.0.21`},
+		},
+		{
+			name:  "IPv6 is canonicalised",
+			field: "2001:DB8:0:0:0:0:0:1",
+			want:  "2001:db8::1",
+		},
+		{
+			name:  "IPv4 mapped IPv6 is unmapped",
+			field: "::ffff:10.0.0.1",
+			want:  "10.0.0.1",
+		},
+		{
+			name:      "forwarded chain uses client address",
+			field:     "203.0.113.7,10.0.0.1",
+			want:      "203.0.113.7",
+			wantFlags: []string{`IP "203.0.113.7,10.0.0.1" is a forwarded chain, using client address 203.0.113.7`},
+		},
+		{
+			name:      "invalid IP is returned verbatim",
+			field:     "not-an-ip",
+			want:      "not-an-ip",
+			wantFlags: []string{`invalid IP "not-an-ip"`},
+		},
+		{
+			name:      "out of range octet is invalid",
+			field:     "10.0.0.256",
+			want:      "10.0.0.256",
+			wantFlags: []string{`invalid IP "10.0.0.256"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, flags := NormaliseIP(tt.field)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantFlags, flags)
+		})
+	}
+}
+
+func Test_IPPrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		ip            string
+		ipv4PrefixLen int
+		ipv6PrefixLen int
+		want          string
+	}{
+		{name: "IPv4 /24", ip: "168.41.191.40", ipv4PrefixLen: 24, want: "168.41.191.0/24"},
+		{name: "IPv4 /16", ip: "168.41.191.40", ipv4PrefixLen: 16, want: "168.41.0.0/16"},
+		{name: "IPv6 /64", ip: "2001:db8:1:2:3:4:5:6", ipv6PrefixLen: 64, want: "2001:db8:1:2::/64"},
+		{name: "IPv4 ungrouped when only IPv6 prefix set", ip: "168.41.191.40", ipv6PrefixLen: 64, want: "168.41.191.40"},
+		{name: "invalid IP is returned verbatim", ip: "not-an-ip", ipv4PrefixLen: 24, want: "not-an-ip"},
+		{name: "prefix length out of range", ip: "168.41.191.40", ipv4PrefixLen: 64, want: "168.41.191.40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IPPrefix(tt.ip, tt.ipv4PrefixLen, tt.ipv6PrefixLen))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package log
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultLatencyInterval is the interval of latency over time used when a LatencyAnalyzer has no interval
+// configured.
+const DefaultLatencyInterval = time.Hour
+
+// LatencyAnalyzer reports on the response times of requests, for log entries parsed with a response time.
+type LatencyAnalyzer struct {
+	// Interval is the length of the time periods of latency over time, e.g. an hour
+	Interval time.Duration
+}
+
+type LatencyAnalysis struct {
+	// RequestCount is the number of requests with a response time, when 0 no log entries had a response time
+	RequestCount int
+	Latency
+	// TopNURLs are the latencies of the most visited URLs
+	TopNURLs []URLLatency
+	// TopNSlowestURLs are the URLs with the highest 90th percentile response time
+	TopNSlowestURLs []URLLatency
+	// OverTime are the latencies of each interval with requests, in time order
+	OverTime []IntervalLatency
+}
+
+// Latency are percentiles of the response times of a group of requests.
+type Latency struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+type URLLatency struct {
+	URL          string
+	RequestCount int
+	Latency
+}
+
+type IntervalLatency struct {
+	Start        time.Time
+	RequestCount int
+	Latency
+}
+
+// GetLatencyAnalysis reports on the percentiles of response times, overall, by URL and over time. Log entries
+// without a response time are ignored, as are log entries without a valid timestamp for latency over time.
+func (a *LatencyAnalyzer) GetLatencyAnalysis(logEntries []LogEntry, topN int) (*LatencyAnalysis, error) {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = DefaultLatencyInterval
+	}
+
+	var all []time.Duration
+	byURL := make(map[string][]time.Duration)
+	byInterval := make(map[time.Time][]time.Duration)
+
+	for _, entry := range logEntries {
+		if entry.ResponseTime == nil {
+			continue
+		}
+
+		responseTime := *entry.ResponseTime
+		all = append(all, responseTime)
+		if !MalformedRequest(entry) {
+			byURL[entry.URL] = append(byURL[entry.URL], responseTime)
+		}
+
+		if !entry.Timestamp.IsZero() {
+			start := entry.Timestamp.Truncate(interval)
+			byInterval[start] = append(byInterval[start], responseTime)
+		}
+	}
+
+	// an analysis of no requests is reported, as a log may not have response times, e.g. in a served time period
+	if len(all) == 0 {
+		return &LatencyAnalysis{}, nil
+	}
+
+	la := &LatencyAnalysis{RequestCount: len(all), Latency: latency(all)}
+
+	urls := make([]URLLatency, 0, len(byURL))
+	for url, responseTimes := range byURL {
+		urls = append(urls, URLLatency{URL: url, RequestCount: len(responseTimes), Latency: latency(responseTimes)})
+	}
+
+	// rank by requests, then by URL to make output deterministic
+	sort.Slice(urls, func(i, j int) bool {
+		if urls[i].RequestCount != urls[j].RequestCount {
+			return urls[i].RequestCount > urls[j].RequestCount
+		}
+		return urls[i].URL < urls[j].URL
+	})
+	la.TopNURLs = append(la.TopNURLs, urls[:min(topN, len(urls))]...)
+
+	sort.SliceStable(urls, func(i, j int) bool {
+		return urls[i].P90 > urls[j].P90
+	})
+	la.TopNSlowestURLs = append(la.TopNSlowestURLs, urls[:min(topN, len(urls))]...)
+
+	for start, responseTimes := range byInterval {
+		la.OverTime = append(la.OverTime, IntervalLatency{Start: start, RequestCount: len(responseTimes), Latency: latency(responseTimes)})
+	}
+
+	sort.Slice(la.OverTime, func(i, j int) bool {
+		return la.OverTime[i].Start.Before(la.OverTime[j].Start)
+	})
+
+	return la, nil
+}
+
+// latency returns the percentiles of response times, sorting them in place.
+func latency(responseTimes []time.Duration) Latency {
+	sort.Slice(responseTimes, func(i, j int) bool { return responseTimes[i] < responseTimes[j] })
+
+	return Latency{
+		P50: percentile(responseTimes, 50),
+		P90: percentile(responseTimes, 90),
+		P99: percentile(responseTimes, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted response times.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LatencyAnalyzer_GetLatencyAnalysis(t *testing.T) {
+	start := time.Date(2018, 7, 11, 17, 0, 0, 0, time.UTC)
+	responseTime := func(ms int) *time.Duration {
+		d := time.Duration(ms) * time.Millisecond
+		return &d
+	}
+	entry := func(url string, minutes int, ms int) LogEntry {
+		return LogEntry{URL: url, Timestamp: start.Add(time.Duration(minutes) * time.Minute), ResponseTime: responseTime(ms)}
+	}
+
+	logEntries := []LogEntry{
+		entry("/", 0, 10),
+		entry("/", 20, 30),
+		entry("/", 70, 20),
+		entry("/docs/", 10, 100),
+		entry("/docs/", 80, 300),
+		entry("/report", 90, 2000),
+		// entries without a response time are ignored
+		{URL: "/faq/", Timestamp: start},
+		// entries without a timestamp are not included over time
+		{URL: "/", ResponseTime: responseTime(40)},
+	}
+
+	got, err := (&LatencyAnalyzer{}).GetLatencyAnalysis(logEntries, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &LatencyAnalysis{
+		RequestCount: 7,
+		Latency:      Latency{P50: 40 * time.Millisecond, P90: 2000 * time.Millisecond, P99: 2000 * time.Millisecond},
+		TopNURLs: []URLLatency{
+			{URL: "/", RequestCount: 4, Latency: Latency{P50: 20 * time.Millisecond, P90: 40 * time.Millisecond, P99: 40 * time.Millisecond}},
+			{URL: "/docs/", RequestCount: 2, Latency: Latency{P50: 100 * time.Millisecond, P90: 300 * time.Millisecond, P99: 300 * time.Millisecond}},
+		},
+		TopNSlowestURLs: []URLLatency{
+			{URL: "/report", RequestCount: 1, Latency: Latency{P50: 2000 * time.Millisecond, P90: 2000 * time.Millisecond, P99: 2000 * time.Millisecond}},
+			{URL: "/docs/", RequestCount: 2, Latency: Latency{P50: 100 * time.Millisecond, P90: 300 * time.Millisecond, P99: 300 * time.Millisecond}},
+		},
+		OverTime: []IntervalLatency{
+			{Start: start, RequestCount: 3, Latency: Latency{P50: 30 * time.Millisecond, P90: 100 * time.Millisecond, P99: 100 * time.Millisecond}},
+			{Start: start.Add(time.Hour), RequestCount: 3, Latency: Latency{P50: 300 * time.Millisecond, P90: 2000 * time.Millisecond, P99: 2000 * time.Millisecond}},
+		},
+	}, got)
+
+	// log entries without response times are an empty analysis
+	got, err = (&LatencyAnalyzer{}).GetLatencyAnalysis(logEntries[6:7], 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &LatencyAnalysis{}, got)
+}
+
+func Test_percentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, time.Duration(5), percentile(sorted, 50))
+	assert.Equal(t, time.Duration(9), percentile(sorted, 90))
+	assert.Equal(t, time.Duration(10), percentile(sorted, 99))
+	assert.Equal(t, time.Duration(1), percentile(sorted, 0))
+	assert.Equal(t, time.Duration(7), percentile([]time.Duration{7}, 99))
+}
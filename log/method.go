@@ -0,0 +1,132 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// httpMethods are the methods registered by RFC 9110 and RFC 5789 (PATCH).
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true,
+	"CONNECT": true, "OPTIONS": true, "TRACE": true, "PATCH": true,
+}
+
+// StandardMethod reports whether a method is registered by RFC 9110 or RFC 5789.
+func StandardMethod(method string) bool {
+	return httpMethods[method]
+}
+
+// httpProtocols are the known protocol versions of a request line, mapped to their version for breakdowns.
+// HTTP/2 and HTTP/3 are logged as HTTP/2.0 and HTTP/3.0 by some servers.
+var httpProtocols = map[string]string{
+	"HTTP/0.9": "HTTP/0.9",
+	"HTTP/1.0": "HTTP/1.0",
+	"HTTP/1.1": "HTTP/1.1",
+	"HTTP/2":   "HTTP/2",
+	"HTTP/2.0": "HTTP/2",
+	"HTTP/3":   "HTTP/3",
+	"HTTP/3.0": "HTTP/3",
+}
+
+// MethodAnalyzer reports on the HTTP methods and protocol versions of requests.
+type MethodAnalyzer struct{}
+
+type MethodAnalysis struct {
+	// MalformedCount is the number of requests whose request line could not be split, which are left out of
+	// the breakdowns
+	MalformedCount int
+	TopNMethods    [][]string
+	// TopNProtocols are the most common protocol versions, e.g. HTTP/1.1 or HTTP/2
+	TopNProtocols [][]string
+	// URLsByMethod are the top URLs requested with each of the top methods
+	URLsByMethod []MethodURLs
+}
+
+// MethodURLs are the URLs most commonly requested with a method.
+type MethodURLs struct {
+	Method   string
+	TopNURLs [][]string
+}
+
+// GetMethodAnalysis breaks down requests by method and protocol version.
+func (a *MethodAnalyzer) GetMethodAnalysis(logEntries []LogEntry, topN int) (*MethodAnalysis, error) {
+	methods := make([]string, 0, len(logEntries))
+	protocols := make([]string, 0, len(logEntries))
+	urls := make(map[string][]string)
+
+	ma := &MethodAnalysis{}
+
+	for _, entry := range logEntries {
+		if MalformedRequest(entry) {
+			ma.MalformedCount++
+			continue
+		}
+
+		methods = append(methods, entry.Method)
+		protocols = append(protocols, ProtocolVersion(entry.Protocol))
+		urls[entry.Method] = append(urls[entry.Method], entry.URL)
+	}
+
+	var err error
+	if ma.TopNMethods, err = getTopNValues("Method", methods, topN); err != nil {
+		return nil, err
+	}
+	if ma.TopNProtocols, err = getTopNValues("Protocol", protocols, topN); err != nil {
+		return nil, err
+	}
+
+	for _, record := range ma.TopNMethods[1:] {
+		topURLs, err := getTopNValues("URL", urls[record[0]], topN)
+		if err != nil {
+			return nil, err
+		}
+
+		ma.URLsByMethod = append(ma.URLsByMethod, MethodURLs{Method: record[0], TopNURLs: topURLs})
+	}
+
+	return ma, nil
+}
+
+// ProtocolVersion returns the version of a known protocol, e.g. HTTP/2 for HTTP/2.0. Unknown protocols are
+// returned unchanged.
+func ProtocolVersion(protocol string) string {
+	if version, ok := httpProtocols[protocol]; ok {
+		return version
+	}
+
+	return protocol
+}
+
+// requestLineFlags validates the method and protocol of a request line, describing any which are malformed or
+// non-standard.
+func requestLineFlags(method string, protocol string) []string {
+	var flags []string
+
+	switch {
+	case !isToken(method):
+		flags = append(flags, fmt.Sprintf("malformed request line, method %q is not a valid token", method))
+	case !httpMethods[method]:
+		flags = append(flags, fmt.Sprintf("non-standard method %q", method))
+	}
+
+	if _, ok := httpProtocols[protocol]; !ok {
+		flags = append(flags, fmt.Sprintf("unknown protocol %q", protocol))
+	}
+
+	return flags
+}
+
+// isToken reports whether s is a token, as methods must be, by RFC 9110.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r > '~' || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MethodAnalyzer_GetMethodAnalysis(t *testing.T) {
+	logEntries := []LogEntry{
+		{Method: "GET", URL: "/", Protocol: "HTTP/1.1"},
+		{Method: "GET", URL: "/faq/", Protocol: "HTTP/2.0"},
+		{Method: "GET", URL: "/", Protocol: "HTTP/2"},
+		{Method: "POST", URL: "/login", Protocol: "HTTP/1.1"},
+		{Method: "POST", URL: "/login", Protocol: "HTTP/1.0"},
+		{Method: "PROPFIND", URL: "/", Protocol: "HTTP/1.1"},
+		{Method: "-", URL: "-", Protocol: "-"},
+		{Method: "-", URL: "-", Protocol: "-"},
+		{Method: "-", URL: "-", Protocol: "-"},
+	}
+
+	got, err := (&MethodAnalyzer{}).GetMethodAnalysis(logEntries, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &MethodAnalysis{
+		MalformedCount: 3,
+		TopNMethods:    [][]string{{"Method", "Method_COUNT"}, {"GET", "3.000000"}, {"POST", "2.000000"}},
+		TopNProtocols:  [][]string{{"Protocol", "Protocol_COUNT"}, {"HTTP/1.1", "3.000000"}, {"HTTP/2", "2.000000"}},
+		URLsByMethod: []MethodURLs{
+			{Method: "GET", TopNURLs: [][]string{{"URL", "URL_COUNT"}, {"/", "2.000000"}, {"/faq/", "1.000000"}}},
+			{Method: "POST", TopNURLs: [][]string{{"URL", "URL_COUNT"}, {"/login", "2.000000"}}},
+		},
+	}, got)
+}
+
+func Test_ProtocolVersion(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{protocol: "HTTP/1.1", want: "HTTP/1.1"},
+		{protocol: "HTTP/2.0", want: "HTTP/2"},
+		{protocol: "HTTP/3.0", want: "HTTP/3"},
+		{protocol: "HTTP/1.2", want: "HTTP/1.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			assert.Equal(t, tt.want, ProtocolVersion(tt.protocol))
+		})
+	}
+}
+
+func Test_requestLineFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		protocol string
+		want     []string
+	}{
+		{name: "standard", method: "GET", protocol: "HTTP/1.1"},
+		{name: "http/2", method: "PATCH", protocol: "HTTP/2.0"},
+		{name: "non-standard method", method: "PROPFIND", protocol: "HTTP/1.1", want: []string{`non-standard method "PROPFIND"`}},
+		{name: "lowercase method", method: "get", protocol: "HTTP/1.1", want: []string{`non-standard method "get"`}},
+		{
+			name:     "binary request line",
+			method:   "\x16\x03\x01",
+			protocol: "HTTP/1.1",
+			want:     []string{`malformed request line, method "\x16\x03\x01" is not a valid token`},
+		},
+		{
+			name:     "unknown protocol",
+			method:   "GET",
+			protocol: "HTTP/1.2",
+			want:     []string{`unknown protocol "HTTP/1.2"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, requestLineFlags(tt.method, tt.protocol))
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package log
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type LogEntry struct {
@@ -18,19 +21,83 @@ type LogEntry struct {
 	Size       int
 	Referrer   string
 	UserAgent  string
+	// Country, ASN and ASOrg are set by a LogEnricher, from the IP
+	Country string
+	ASN     int
+	ASOrg   string
+	// Timestamp is the parsed Time, or the zero time if Time is invalid
+	Timestamp time.Time `dataframe:"-"`
+	// ResponseTime is the time taken to serve the request, or nil if it was not logged or is not parsed
+	ResponseTime *time.Duration `dataframe:"-"`
+	// Extra are the named fields logged after the user agent, e.g. the virtual host or request ID
+	Extra map[string]string `dataframe:"-"`
+	// Flags describe suspect fields in an otherwise parsable log line
+	Flags []string `dataframe:"-"`
 }
 
 type LogParser interface {
 	ParseLogEntry(string) (LogEntry, error)
-	ParseLogEntries([]string) ([]LogEntry, error)
+	ParseLogEntries([]string) ([]LogEntry, *ParseReport, error)
 }
 
-type CombinedLogParser struct{}
+// ParseReport summarises the outcome of parsing log lines.
+type ParseReport struct {
+	TotalLines int
+	// Errors are lines which could not be parsed, and were omitted
+	Errors []ParseIssue
+	// Warnings are lines which were parsed, but were flagged as suspect
+	Warnings []ParseIssue
+}
+
+type ParseIssue struct {
+	LineNumber int
+	Message    string
+}
+
+// OffsetLineNumbers adds an offset to the line numbers of the issues, for lines parsed from part way through a
+// log.
+func (r *ParseReport) OffsetLineNumbers(offset int) {
+	for i := range r.Errors {
+		r.Errors[i].LineNumber += offset
+	}
+	for i := range r.Warnings {
+		r.Warnings[i].LineNumber += offset
+	}
+}
+
+// ParsedLines returns the number of log lines which were parsed successfully.
+func (r *ParseReport) ParsedLines() int {
+	return r.TotalLines - len(r.Errors)
+}
+
+type CombinedLogParser struct {
+	// ResponseTime parses the response time of requests from a field after the user agent when not nil.
+	ResponseTime *ResponseTimeField
+	// ExtraFields name the fields logged after the user agent, in order, which are kept in the Extra map of log
+	// entries. Fields named "" or "-" are skipped.
+	ExtraFields []string
+}
+
+// ResponseTimeField locates the response time of requests among the fields logged after the user agent, e.g.
+// by Apache's %D or %T, or nginx's $request_time.
+type ResponseTimeField struct {
+	// Position is the 1-based position of the response time among the fields after the user agent
+	Position int
+	// Unit is the unit of the logged response time: microseconds for %D, or seconds for %T and $request_time,
+	// which may have a fraction
+	Unit time.Duration
+}
+
+// Combined Log Format (CLF) regex, compiled once as it is used for every log line. Quoted fields may contain
+// quotes escaped with a backslash, and the size is "-" for responses without a body. Any fields after the user
+// agent are captured as logged.
+var clfRegex = regexp.MustCompile(`^(\S+(?:,\s*\S+)*) (\S+) (\S+) \[([\w:/]+\s[+\-]\d{4})\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-) "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"(.*)`)
+
+// unescapeQuoted reverses the escaping of quotes and backslashes in a quoted field, e.g. a user agent
+// containing \"quotes\". Other escape sequences, such as \x16 for binary data, are left as logged.
+var unescapeQuoted = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace
 
 func (p *CombinedLogParser) ParseLogEntry(line string) (LogEntry, error) {
-	// Combined Log Format (CLF) regex
-	const clfRgx = `^(\S+) (\S+) (\S+) \[([\w:/]+\s[+\-]\d{4})\] "(\S+) (\S+) (\S+)" (\d{3}) (\d+) "([^"]*)" "([^"]*)".*`
-	clfRegex := regexp.MustCompile(clfRgx)
 	logFields := clfRegex.FindStringSubmatch(line)
 
 	// regex parse error
@@ -39,51 +106,211 @@ func (p *CombinedLogParser) ParseLogEntry(line string) (LogEntry, error) {
 	}
 
 	// log parsed successfully
-	statusCode, err := ParseInt(logFields[8])
+	statusCode, err := ParseInt(logFields[6])
 	if err != nil {
 		return LogEntry{}, err
 	}
 
-	size, err := ParseInt(logFields[9])
+	// a size of "-" is logged for responses without a body
+	size := 0
+	if logFields[7] != "-" {
+		size, err = ParseInt(logFields[7])
+		if err != nil {
+			return LogEntry{}, err
+		}
+	}
+
+	ip, flags := NormaliseIP(logFields[1])
+
+	timestamp, err := ParseLogTime(logFields[4])
 	if err != nil {
-		return LogEntry{}, err
+		flags = append(flags, fmt.Sprintf("invalid time %q", logFields[4]))
 	}
 
+	method, url, protocol, requestFlags := parseRequestLine(unescapeQuoted(logFields[5]))
+	flags = append(flags, requestFlags...)
+
 	logEntry := LogEntry{
-		IP:         logFields[1],
+		IP:         ip,
 		Identity:   logFields[2],
 		UserID:     logFields[3],
 		Time:       logFields[4],
-		Method:     logFields[5],
-		URL:        logFields[6],
-		Protocol:   logFields[7],
+		Timestamp:  timestamp,
+		Method:     method,
+		URL:        url,
+		Protocol:   protocol,
 		StatusCode: statusCode,
 		Size:       size,
-		Referrer:   logFields[10],
-		UserAgent:  logFields[11],
+		Referrer:   unescapeQuoted(logFields[8]),
+		UserAgent:  unescapeQuoted(logFields[9]),
+		Flags:      flags,
+	}
+
+	if p.ResponseTime == nil && len(p.ExtraFields) == 0 {
+		return logEntry, nil
+	}
+
+	trailingFields := splitTrailingFields(logFields[10])
+
+	if p.ResponseTime != nil {
+		responseTime, err := p.ResponseTime.parse(trailingFields)
+		if err != nil {
+			logEntry.Flags = append(logEntry.Flags, err.Error())
+		}
+		logEntry.ResponseTime = responseTime
+	}
+
+	if len(p.ExtraFields) > 0 {
+		logEntry.Extra, logEntry.Flags = p.extraFields(trailingFields, logEntry.Flags)
 	}
 
 	return logEntry, nil
 }
 
-func (p *CombinedLogParser) ParseLogEntries(logLines []string) ([]LogEntry, error) {
+// extraFields names the fields after the user agent, flagging lines with fewer fields than are named.
+func (p *CombinedLogParser) extraFields(fields []string, flags []string) (map[string]string, []string) {
+	extra := make(map[string]string, len(p.ExtraFields))
+	var missing []string
+
+	for i, name := range p.ExtraFields {
+		switch {
+		case name == "" || name == "-":
+		case i >= len(fields):
+			missing = append(missing, name)
+		default:
+			extra[name] = fields[i]
+		}
+	}
+
+	if len(missing) > 0 {
+		flags = append(flags, fmt.Sprintf("missing extra fields after the user agent: %s", strings.Join(missing, ", ")))
+	}
+
+	return extra, flags
+}
+
+// parse returns the response time in the fields after the user agent. A response time of "-" is not logged,
+// and nil is returned without an error.
+func (f *ResponseTimeField) parse(fields []string) (*time.Duration, error) {
+	if f.Position < 1 || f.Position > len(fields) {
+		return nil, fmt.Errorf("missing response time, expected in field %d after the user agent", f.Position)
+	}
+
+	value := fields[f.Position-1]
+	if value == "-" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid response time %q", value)
+	}
+
+	responseTime := time.Duration(n * float64(f.Unit))
+
+	return &responseTime, nil
+}
+
+// splitTrailingFields splits the text logged after the user agent into fields separated by spaces. Quoted
+// fields, which may contain spaces and escaped quotes, are returned without their quotes.
+func splitTrailingFields(text string) []string {
+	var fields []string
+
+	for text = strings.TrimLeft(text, " \t"); text != ""; text = strings.TrimLeft(text, " \t") {
+		if text[0] == '"' {
+			end := 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			fields = append(fields, unescapeQuoted(text[1:min(end, len(text))]))
+			text = text[min(end+1, len(text)):]
+			continue
+		}
+
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+
+		fields = append(fields, text[:end])
+		text = text[end:]
+	}
+
+	return fields
+}
+
+// MalformedRequest reports whether the request line of a log entry could not be split, so its method, URL and
+// protocol are "-". Malformed requests are listed as parse warnings, and are left out of rankings of methods,
+// protocols and URLs.
+func MalformedRequest(entry LogEntry) bool {
+	return entry.Method == "-" && entry.Protocol == "-"
+}
+
+// parseRequestLine splits a request line into its method, URL and protocol, flagging any which are malformed
+// or non-standard:
+//   - "GET /faq/ HTTP/1.1" is a standard request.
+//   - "GET /faq/" is an HTTP/0.9 request, which has no protocol.
+//   - "GET /a b HTTP/1.1" has an unencoded space in its URL, and is flagged.
+//   - "-", logged when no request line was received, and other request lines which cannot be split have a
+//     method, URL and protocol of "-", and are flagged.
+func parseRequestLine(request string) (method string, url string, protocol string, flags []string) {
+	fields := strings.Split(request, " ")
+
+	switch {
+	case len(fields) == 2 && fields[0] != "" && fields[1] != "":
+		method, url, protocol = fields[0], fields[1], "HTTP/0.9"
+	case len(fields) == 3 && fields[0] != "" && fields[1] != "" && fields[2] != "":
+		method, url, protocol = fields[0], fields[1], fields[2]
+	case len(fields) > 3 && fields[0] != "" && strings.HasPrefix(fields[len(fields)-1], "HTTP/"):
+		method, url, protocol = fields[0], strings.Join(fields[1:len(fields)-1], " "), fields[len(fields)-1]
+		flags = append(flags, fmt.Sprintf("malformed request line, URL %q contains spaces", url))
+	default:
+		return "-", "-", "-", []string{fmt.Sprintf("malformed request line %q", request)}
+	}
+
+	return method, url, protocol, append(flags, requestLineFlags(method, protocol)...)
+}
+
+// ErrNoLogEntries is returned by ParseLogEntries when no log lines were parsed successfully, with the report of
+// any lines which could not be parsed.
+var ErrNoLogEntries = errors.New("no log entries parsed successfully")
+
+func (p *CombinedLogParser) ParseLogEntries(logLines []string) ([]LogEntry, *ParseReport, error) {
 	var logEntries []LogEntry
+	report := &ParseReport{TotalLines: len(logLines)}
 
-	for _, line := range logLines {
+	for i, line := range logLines {
 		entry, err := p.ParseLogEntry(line)
 		if err != nil {
-			fmt.Printf("error parsing log entry, omitting: %v\n", err)
+			report.Errors = append(report.Errors, ParseIssue{LineNumber: i + 1, Message: err.Error()})
 			continue
 		}
 
+		for _, flag := range entry.Flags {
+			report.Warnings = append(report.Warnings, ParseIssue{LineNumber: i + 1, Message: flag})
+		}
+
 		logEntries = append(logEntries, entry)
 	}
 
 	if len(logEntries) == 0 {
-		return nil, fmt.Errorf("no log entries parsed successfully")
+		return nil, report, ErrNoLogEntries
 	}
 
-	return logEntries, nil
+	return logEntries, report, nil
+}
+
+// LogTimeLayout is the layout of the time field in common and combined log format.
+const LogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ParseLogTime parses the time field of a log line, e.g. 10/Jul/2018:22:21:28 +0200.
+func ParseLogTime(str string) (time.Time, error) {
+	// parse in UTC so the location of the timestamp does not depend on the local time zone
+	return time.ParseInLocation(LogTimeLayout, str, time.UTC)
 }
 
 func ParseInt(str string) (int, error) {
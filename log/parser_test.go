@@ -1,7 +1,10 @@
 package log
 
 import (
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,6 +26,7 @@ func Test_CombinedLogParser_ParseLogEntry(t *testing.T) {
 				Identity:   "-",
 				UserID:     "-",
 				Time:       "01/Jan/2022:00:00:00 +0000",
+				Timestamp:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
 				Method:     "GET",
 				URL:        "/",
 				Protocol:   "HTTP/1.1",
@@ -33,6 +37,66 @@ func Test_CombinedLogParser_ParseLogEntry(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "parse log entry with leading zero IP is normalised and flagged",
+			line: `79.125.00.21 - admin [10/Jul/2018:20:03:40 +0200] "GET /newsletter/ HTTP/1.1" 200 3574 "-" "Mozilla/5.0"`,
+			want: LogEntry{
+				IP:         "79.125.0.21",
+				Identity:   "-",
+				UserID:     "admin",
+				Time:       "10/Jul/2018:20:03:40 +0200",
+				Timestamp:  time.Date(2018, 7, 10, 20, 3, 40, 0, time.FixedZone("", 2*60*60)),
+				Method:     "GET",
+				URL:        "/newsletter/",
+				Protocol:   "HTTP/1.1",
+				StatusCode: 200,
+				Size:       3574,
+				Referrer:   "-",
+				UserAgent:  "Mozilla/5.0",
+				Flags:      []string{`IP "79.125.00.21" has leading zeros, normalised to 79.125.0.21`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "parse log entry with forwarded IP chain",
+			line: `203.0.113.7, 10.0.0.1 - - [10/Jul/2018:20:03:40 +0200] "GET / HTTP/1.1" 200 3574 "-" "Mozilla/5.0"`,
+			want: LogEntry{
+				IP:         "203.0.113.7",
+				Identity:   "-",
+				UserID:     "-",
+				Time:       "10/Jul/2018:20:03:40 +0200",
+				Timestamp:  time.Date(2018, 7, 10, 20, 3, 40, 0, time.FixedZone("", 2*60*60)),
+				Method:     "GET",
+				URL:        "/",
+				Protocol:   "HTTP/1.1",
+				StatusCode: 200,
+				Size:       3574,
+				Referrer:   "-",
+				UserAgent:  "Mozilla/5.0",
+				Flags:      []string{`IP "203.0.113.7, 10.0.0.1" is a forwarded chain, using client address 203.0.113.7`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "parse log entry with non-standard method is flagged",
+			line: `10.0.0.1 - - [10/Jul/2018:20:03:40 +0200] "PROPFIND /dav/ HTTP/1.1" 207 512 "-" "Microsoft-WebDAV-MiniRedir/10.0.19045"`,
+			want: LogEntry{
+				IP:         "10.0.0.1",
+				Identity:   "-",
+				UserID:     "-",
+				Time:       "10/Jul/2018:20:03:40 +0200",
+				Timestamp:  time.Date(2018, 7, 10, 20, 3, 40, 0, time.FixedZone("", 2*60*60)),
+				Method:     "PROPFIND",
+				URL:        "/dav/",
+				Protocol:   "HTTP/1.1",
+				StatusCode: 207,
+				Size:       512,
+				Referrer:   "-",
+				UserAgent:  "Microsoft-WebDAV-MiniRedir/10.0.19045",
+				Flags:      []string{`non-standard method "PROPFIND"`},
+			},
+			wantErr: false,
+		},
 		{
 			name:    "parse invalid log entry throws error",
 			line:    "invalid log entry",
@@ -55,6 +119,237 @@ func Test_CombinedLogParser_ParseLogEntry(t *testing.T) {
 	}
 }
 
+// Test_CombinedLogParser_ParseLogEntry_trickyLines parses a corpus of real-world log lines which deviate from
+// the combined log format, checking the request, size and quoted fields they are mapped to.
+func Test_CombinedLogParser_ParseLogEntry_trickyLines(t *testing.T) {
+	data, err := os.ReadFile("testdata/tricky.log")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	parser := &CombinedLogParser{}
+
+	tests := []struct {
+		name      string
+		line      int
+		method    string
+		url       string
+		protocol  string
+		size      int
+		referrer  string
+		userAgent string
+		flags     []string
+		wantErr   bool
+	}{
+		{
+			name: "no request line", line: 1,
+			method: "-", url: "-", protocol: "-", size: 0, referrer: "-", userAgent: "-",
+			flags: []string{`malformed request line "-"`},
+		},
+		{
+			name: "HTTP/0.9 request", line: 2,
+			method: "GET", url: "/health", protocol: "HTTP/0.9", size: 2, referrer: "-", userAgent: "-",
+		},
+		{
+			name: "no response body", line: 3,
+			method: "HEAD", url: "/", protocol: "HTTP/1.1", size: 0, referrer: "-", userAgent: "curl/7.61.0",
+		},
+		{
+			name: "escaped quotes in user agent", line: 4,
+			method: "GET", url: "/", protocol: "HTTP/1.1", size: 612, referrer: "-",
+			userAgent: `Mozilla/5.0 (compatible; "Quoted" Bot/1.0; +http://example.com/bot)`,
+		},
+		{
+			name: "escaped quotes in request and referrer", line: 5,
+			method: "GET", url: `/search?q="exact phrase"`, protocol: "HTTP/1.1", size: 4096, referrer: `http://example.net/?q="a"`, userAgent: "Mozilla/5.0",
+			flags: []string{`malformed request line, URL "/search?q=\"exact phrase\"" contains spaces`},
+		},
+		{
+			name: "TLS handshake to plain HTTP port", line: 6,
+			method: "-", url: "-", protocol: "-", size: 226, referrer: "-", userAgent: "-",
+			flags: []string{`malformed request line "\\x16\\x03\\x01\\x02\\x00\\x01\\x00\\x01\\xfc\\x03\\x03"`},
+		},
+		{
+			name: "unencoded space in URL", line: 7,
+			method: "GET", url: "/docs/getting started.html", protocol: "HTTP/1.1", size: 153, referrer: "-", userAgent: "Mozilla/5.0",
+			flags: []string{`malformed request line, URL "/docs/getting started.html" contains spaces`},
+		},
+		{
+			name: "HTTP/2 request", line: 8,
+			method: "GET", url: "/", protocol: "HTTP/2.0", size: 612, referrer: "-",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		},
+		{
+			name: "empty request line", line: 9,
+			method: "-", url: "-", protocol: "-", size: 0, referrer: "-", userAgent: "-",
+			flags: []string{`malformed request line ""`},
+		},
+		{
+			name: "escaped backslashes", line: 10,
+			method: "GET", url: `/a\b`, protocol: "HTTP/1.1", size: 153, referrer: "-", userAgent: `Mozilla/5.0 \ backslash`,
+		},
+		{
+			name: "server internal dummy connection", line: 11,
+			method: "OPTIONS", url: "*", protocol: "HTTP/1.0", size: 0, referrer: "-", userAgent: "Apache (internal dummy connection)",
+		},
+		{
+			name: "trailing fields are ignored", line: 12,
+			method: "GET", url: "/", protocol: "HTTP/0.9", size: 0, referrer: "-", userAgent: "-",
+		},
+		{
+			name: "missing user agent is rejected", line: 13,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.ParseLogEntry(lines[tt.line-1])
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.method, got.Method)
+			assert.Equal(t, tt.url, got.URL)
+			assert.Equal(t, tt.protocol, got.Protocol)
+			assert.Equal(t, tt.size, got.Size)
+			assert.Equal(t, tt.referrer, got.Referrer)
+			assert.Equal(t, tt.userAgent, got.UserAgent)
+			assert.Equal(t, tt.flags, got.Flags)
+		})
+	}
+}
+
+func Test_CombinedLogParser_ParseLogEntry_responseTime(t *testing.T) {
+	const prefix = `127.0.0.1 - - [01/Jan/2022:00:00:00 +0000] "GET / HTTP/1.1" 200 1234 "-" "Mozilla/5.0"`
+	duration := func(d time.Duration) *time.Duration { return &d }
+
+	tests := []struct {
+		name  string
+		field ResponseTimeField
+		line  string
+		want  *time.Duration
+		flags []string
+	}{
+		{
+			name:  "microseconds",
+			field: ResponseTimeField{Position: 1, Unit: time.Microsecond},
+			line:  prefix + ` 52341`,
+			want:  duration(52341 * time.Microsecond),
+		},
+		{
+			name:  "fractional seconds after a quoted field",
+			field: ResponseTimeField{Position: 2, Unit: time.Second},
+			line:  prefix + ` "example.com \"vhost\"" 0.125`,
+			want:  duration(125 * time.Millisecond),
+		},
+		{
+			name:  "not logged",
+			field: ResponseTimeField{Position: 1, Unit: time.Second},
+			line:  prefix + ` -`,
+		},
+		{
+			name:  "missing",
+			field: ResponseTimeField{Position: 2, Unit: time.Microsecond},
+			line:  prefix + ` 52341`,
+			flags: []string{"missing response time, expected in field 2 after the user agent"},
+		},
+		{
+			name:  "invalid",
+			field: ResponseTimeField{Position: 1, Unit: time.Microsecond},
+			line:  prefix + ` fast`,
+			flags: []string{`invalid response time "fast"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &CombinedLogParser{ResponseTime: &tt.field}
+
+			got, err := parser.ParseLogEntry(tt.line)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.ResponseTime)
+			assert.Equal(t, tt.flags, got.Flags)
+		})
+	}
+}
+
+func Test_CombinedLogParser_ParseLogEntry_extraFields(t *testing.T) {
+	const prefix = `127.0.0.1 - - [01/Jan/2022:00:00:00 +0000] "GET / HTTP/1.1" 200 1234 "-" "Mozilla/5.0"`
+	parser := &CombinedLogParser{
+		ResponseTime: &ResponseTimeField{Position: 2, Unit: time.Microsecond},
+		ExtraFields:  []string{"vhost", "-", "request-id"},
+	}
+
+	got, err := parser.ParseLogEntry(prefix + ` example.com 52341 "abc 123"`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vhost": "example.com", "request-id": "abc 123"}, got.Extra)
+	assert.Empty(t, got.Flags)
+
+	got, err = parser.ParseLogEntry(prefix + ` example.com`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vhost": "example.com"}, got.Extra)
+	assert.Equal(t, []string{
+		"missing response time, expected in field 2 after the user agent",
+		"missing extra fields after the user agent: request-id",
+	}, got.Flags)
+}
+
+func Test_splitTrailingFields(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: nil},
+		{text: " 52341", want: []string{"52341"}},
+		{text: ` example.com  "req \"id\" 1" -`, want: []string{"example.com", `req "id" 1`, "-"}},
+		{text: ` "unterminated`, want: []string{"unterminated"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitTrailingFields(tt.text))
+		})
+	}
+}
+
+func Test_ParseLogTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "parse UTC time",
+			str:  "01/Jan/2022:00:00:00 +0000",
+			want: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "parse time with offset",
+			str:  "10/Jul/2018:22:21:28 +0200",
+			want: time.Date(2018, 7, 10, 20, 21, 28, 0, time.UTC),
+		},
+		{
+			name:    "parse invalid time",
+			str:     "10/Jul/2018 22:21:28",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLogTime(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLogTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.True(t, tt.want.Equal(got), "want %v, got %v", tt.want, got)
+		})
+	}
+}
+
 func Test_ParseInt(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -98,10 +393,11 @@ func Test_CombinedLogParser_ParseLogEntries(t *testing.T) {
 	parser := &CombinedLogParser{}
 
 	tests := []struct {
-		name     string
-		logLines []string
-		wantLen  int
-		wantErr  bool
+		name       string
+		logLines   []string
+		wantLen    int
+		wantReport *ParseReport
+		wantErr    bool
 	}{
 		{
 			name: "parse valid log lines",
@@ -109,7 +405,23 @@ func Test_CombinedLogParser_ParseLogEntries(t *testing.T) {
 				"127.0.0.1 - - [01/Jan/2022:00:00:00 +0000] \"GET / HTTP/1.1\" 200 1234 \"-\" \"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3\"",
 				"127.0.0.1 - - [01/Jan/2022:00:00:01 +0000] \"GET /about HTTP/1.1\" 200 5678 \"-\" \"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3\"",
 			},
+			wantLen:    2,
+			wantReport: &ParseReport{TotalLines: 2},
+			wantErr:    false,
+		},
+		{
+			name: "parse log lines with errors and warnings",
+			logLines: []string{
+				"127.0.0.1 - - [01/Jan/2022:00:00:00 +0000] \"GET / HTTP/1.1\" 200 1234 \"-\" \"Mozilla/5.0\"",
+				"invalid log line",
+				"bad-ip - - [01/Jan/2022:00:00:01 +0000] \"GET /about HTTP/1.1\" 200 5678 \"-\" \"Mozilla/5.0\"",
+			},
 			wantLen: 2,
+			wantReport: &ParseReport{
+				TotalLines: 3,
+				Errors:     []ParseIssue{{LineNumber: 2, Message: "log parsing error for line: invalid log line"}},
+				Warnings:   []ParseIssue{{LineNumber: 3, Message: `invalid IP "bad-ip"`}},
+			},
 			wantErr: false,
 		},
 		{
@@ -119,18 +431,52 @@ func Test_CombinedLogParser_ParseLogEntries(t *testing.T) {
 				"another invalid log line",
 			},
 			wantLen: 0,
+			wantReport: &ParseReport{
+				TotalLines: 2,
+				Errors: []ParseIssue{
+					{LineNumber: 1, Message: "log parsing error for line: invalid log line"},
+					{LineNumber: 2, Message: "log parsing error for line: another invalid log line"},
+				},
+			},
 			wantErr: true,
 		},
+		{
+			name:       "parse no log lines",
+			logLines:   []string{},
+			wantLen:    0,
+			wantReport: &ParseReport{},
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parser.ParseLogEntries(tt.logLines)
+			got, report, err := parser.ParseLogEntries(tt.logLines)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ParseLogEntries() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if err != nil {
+				assert.ErrorIs(t, err, ErrNoLogEntries)
+			}
 			assert.Len(t, got, tt.wantLen)
+			assert.Equal(t, tt.wantReport, report)
+			assert.Equal(t, tt.wantLen, report.ParsedLines())
 		})
 	}
 }
+
+func Test_ParseReport_OffsetLineNumbers(t *testing.T) {
+	report := &ParseReport{
+		TotalLines: 3,
+		Errors:     []ParseIssue{{LineNumber: 2, Message: "log parsing error for line: invalid log line"}},
+		Warnings:   []ParseIssue{{LineNumber: 3, Message: `invalid IP "bad-ip"`}},
+	}
+
+	report.OffsetLineNumbers(100)
+	assert.Equal(t, &ParseReport{
+		TotalLines: 3,
+		Errors:     []ParseIssue{{LineNumber: 102, Message: "log parsing error for line: invalid log line"}},
+		Warnings:   []ParseIssue{{LineNumber: 103, Message: `invalid IP "bad-ip"`}},
+	}, report)
+}
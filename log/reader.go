@@ -2,6 +2,7 @@ package log
 
 import (
 	"bufio"
+	"io"
 	"os"
 )
 
@@ -13,6 +14,12 @@ type FileReader struct {
 	LogFilePath string
 }
 
+// StdinReader reads log lines piped in on standard input, e.g. `zcat access.log.gz | digio-task -`.
+type StdinReader struct {
+	// Stdin is the input to read from, defaults to os.Stdin when nil.
+	Stdin io.Reader
+}
+
 // ReadLines reads a whole file into memory as a slice of strings.
 func (r *FileReader) ReadLines() ([]string, error) {
 	file, err := os.Open(r.LogFilePath)
@@ -21,7 +28,22 @@ func (r *FileReader) ReadLines() ([]string, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return readLines(file)
+}
+
+// ReadLines reads the whole of standard input into memory as a slice of strings.
+func (r *StdinReader) ReadLines() ([]string, error) {
+	stdin := r.Stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	return readLines(stdin)
+}
+
+// readLines reads all lines from an io.Reader into a slice of strings.
+func readLines(reader io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(reader)
 	lines := make([]string, 0)
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
@@ -2,6 +2,7 @@ package log
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,3 +56,36 @@ func Test_FileReader_ReadLines(t *testing.T) {
 		})
 	}
 }
+
+func Test_StdinReader_ReadLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "read piped input",
+			input: "line 1\nline 2\nline 3\n",
+			want:  []string{"line 1", "line 2", "line 3"},
+		},
+		{
+			name:  "read input without trailing newline",
+			input: "line 1\nline 2",
+			want:  []string{"line 1", "line 2"},
+		},
+		{
+			name:  "read empty input",
+			input: "",
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &StdinReader{Stdin: strings.NewReader(tt.input)}
+			got, err := reader.ReadLines()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package log
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SearchEngine identifies referrals from a search engine by the host of the referrer, and the query parameters
+// which hold the search terms.
+type SearchEngine struct {
+	Name        string
+	Host        *regexp.Regexp
+	QueryParams []string
+}
+
+// DefaultSearchEngines are used when a ReferrerAnalyzer has no SearchEngines configured.
+var DefaultSearchEngines = []SearchEngine{
+	{Name: "Google", Host: regexp.MustCompile(`(^|\.)google\.[a-z.]+$`), QueryParams: []string{"q"}},
+	{Name: "Bing", Host: regexp.MustCompile(`(^|\.)bing\.com$`), QueryParams: []string{"q"}},
+	{Name: "Yahoo", Host: regexp.MustCompile(`(^|\.)search\.yahoo\.[a-z.]+$`), QueryParams: []string{"p"}},
+	{Name: "DuckDuckGo", Host: regexp.MustCompile(`(^|\.)duckduckgo\.com$`), QueryParams: []string{"q"}},
+	{Name: "Baidu", Host: regexp.MustCompile(`(^|\.)baidu\.com$`), QueryParams: []string{"wd", "word"}},
+	{Name: "Yandex", Host: regexp.MustCompile(`(^|\.)yandex\.[a-z.]+$`), QueryParams: []string{"text"}},
+	{Name: "Ecosia", Host: regexp.MustCompile(`(^|\.)ecosia\.org$`), QueryParams: []string{"q"}},
+}
+
+// unknownReferrerDomain groups external referrers which cannot be parsed or have no host, such as "about:blank".
+const unknownReferrerDomain = "(unknown)"
+
+// ReferrerAnalyzer attributes requests to their traffic source using the referrer of each request.
+type ReferrerAnalyzer struct {
+	// InternalHosts are the hosts of the site itself, referrals from which are navigation within the site. The
+	// hosts of absolute request URLs are also treated as internal.
+	InternalHosts []string
+	SearchEngines []SearchEngine
+}
+
+type ReferrerAnalysis struct {
+	// DirectCount, InternalCount and ExternalCount split requests by traffic source: no referrer, a referrer
+	// within the site, or a referrer from another site
+	DirectCount   int
+	InternalCount int
+	ExternalCount int
+	// SearchCount is the number of external referrals from search engines
+	SearchCount int
+	// TopNDomains are the most common external referring domains, with the www. prefix removed
+	TopNDomains       [][]string
+	TopNSearchEngines [][]string
+	// TopNSearchTerms are the most common search terms, for search engines which still pass them on
+	TopNSearchTerms [][]string
+	// LandingPages are the top landing pages of each of the top referring domains
+	LandingPages []ReferrerLandingPages
+}
+
+// ReferrerLandingPages are the pages most commonly requested from a referring domain.
+type ReferrerLandingPages struct {
+	Domain           string
+	TopNLandingPages [][]string
+}
+
+// GetReferrerAnalysis reports on where requests came from. siteHosts are the hosts of the site in addition to
+// the configured InternalHosts, such as the hosts of absolute request URLs.
+func (a *ReferrerAnalyzer) GetReferrerAnalysis(logEntries []LogEntry, siteHosts []string, topN int) (*ReferrerAnalysis, error) {
+	searchEngines := a.SearchEngines
+	if searchEngines == nil {
+		searchEngines = DefaultSearchEngines
+	}
+
+	internal := make(map[string]bool)
+	for _, hosts := range [][]string{a.InternalHosts, siteHosts} {
+		for _, host := range hosts {
+			internal[normaliseHost(host)] = true
+		}
+	}
+
+	ra := &ReferrerAnalysis{}
+
+	var domains, engines, terms []string
+	landingPages := make(map[string][]string)
+
+	for _, entry := range logEntries {
+		if entry.Referrer == "" || entry.Referrer == "-" {
+			ra.DirectCount++
+			continue
+		}
+
+		u := parseReferrer(entry.Referrer)
+		domain := unknownReferrerDomain
+		if u != nil && u.Host != "" {
+			domain = normaliseHost(u.Host)
+		}
+
+		if internal[domain] {
+			ra.InternalCount++
+			continue
+		}
+
+		ra.ExternalCount++
+		domains = append(domains, domain)
+		landingPages[domain] = append(landingPages[domain], entry.URL)
+
+		if engine, ok := matchSearchEngine(searchEngines, domain); ok {
+			ra.SearchCount++
+			engines = append(engines, engine.Name)
+			if term := searchTerms(u, engine); term != "" {
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	var err error
+	if ra.TopNDomains, err = getTopNValues("Domain", domains, topN); err != nil {
+		return nil, err
+	}
+	if ra.TopNSearchEngines, err = getTopNValues("SearchEngine", engines, topN); err != nil {
+		return nil, err
+	}
+	if ra.TopNSearchTerms, err = getTopNValues("SearchTerm", terms, topN); err != nil {
+		return nil, err
+	}
+
+	for _, record := range ra.TopNDomains[1:] {
+		pages, err := getTopNValues("LandingPage", landingPages[record[0]], topN)
+		if err != nil {
+			return nil, err
+		}
+
+		ra.LandingPages = append(ra.LandingPages, ReferrerLandingPages{Domain: record[0], TopNLandingPages: pages})
+	}
+
+	return ra, nil
+}
+
+// RequestHosts returns the distinct hosts of absolute request URLs, such as example.net for
+// "GET http://example.net/faq/", in order of first appearance.
+func RequestHosts(logEntries []LogEntry) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+
+	for _, entry := range logEntries {
+		u, err := url.Parse(entry.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		if host := normaliseHost(u.Host); !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// parseReferrer parses a referrer, which may be given without a scheme, e.g. "example.com/page". It returns
+// nil for referrers which cannot be parsed.
+func parseReferrer(referrer string) *url.URL {
+	if !strings.Contains(referrer, "://") {
+		referrer = "http://" + referrer
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
+
+// normaliseHost lowercases a host and removes its port and www. prefix, so that www.example.com:443 and
+// example.com are the same domain.
+func normaliseHost(host string) string {
+	host = strings.ToLower(host)
+	if u, err := url.Parse("http://" + host); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	return strings.TrimPrefix(host, "www.")
+}
+
+func matchSearchEngine(engines []SearchEngine, domain string) (SearchEngine, bool) {
+	for _, engine := range engines {
+		if engine.Host.MatchString(domain) {
+			return engine, true
+		}
+	}
+
+	return SearchEngine{}, false
+}
+
+// searchTerms extracts the search terms from the query of a search engine referrer, lowercased and with
+// whitespace collapsed, or "" when the search engine did not pass them on.
+func searchTerms(u *url.URL, engine SearchEngine) string {
+	query := u.Query()
+	for _, param := range engine.QueryParams {
+		if terms := strings.Join(strings.Fields(strings.ToLower(query.Get(param))), " "); terms != "" {
+			return terms
+		}
+	}
+
+	return ""
+}
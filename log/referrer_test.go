@@ -0,0 +1,106 @@
+package log
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReferrerAnalyzer_GetReferrerAnalysis(t *testing.T) {
+	logEntries := []LogEntry{
+		{URL: "/", Referrer: "-"},
+		{URL: "/faq/", Referrer: ""},
+		{URL: "/docs/", Referrer: "http://example.net/"},
+		{URL: "/docs/", Referrer: "https://www.example.net:443/faq/"},
+		{URL: "/", Referrer: "https://www.google.com/search?q=Digio++Careers"},
+		{URL: "/careers/", Referrer: "https://www.google.com.au/search?q=digio%20careers&hl=en"},
+		{URL: "/", Referrer: "https://www.google.com/"},
+		{URL: "/blog/", Referrer: "https://duckduckgo.com/?q=log+analysis"},
+		{URL: "/blog/", Referrer: "news.ycombinator.com/item?id=1"},
+		{URL: "/blog/", Referrer: "https://news.ycombinator.com/"},
+		{URL: "/", Referrer: "android-app://com.slack/"},
+	}
+
+	tests := []struct {
+		name      string
+		analyzer  *ReferrerAnalyzer
+		siteHosts []string
+		topN      int
+		want      *ReferrerAnalysis
+	}{
+		{
+			name:      "traffic sources",
+			analyzer:  &ReferrerAnalyzer{},
+			siteHosts: []string{"example.net"},
+			topN:      2,
+			want: &ReferrerAnalysis{
+				DirectCount:   2,
+				InternalCount: 2,
+				ExternalCount: 7,
+				SearchCount:   4,
+				TopNDomains: [][]string{
+					{"Domain", "Domain_COUNT"},
+					{"google.com", "2.000000"},
+					{"news.ycombinator.com", "2.000000"},
+				},
+				TopNSearchEngines: [][]string{
+					{"SearchEngine", "SearchEngine_COUNT"},
+					{"Google", "3.000000"},
+					{"DuckDuckGo", "1.000000"},
+				},
+				TopNSearchTerms: [][]string{
+					{"SearchTerm", "SearchTerm_COUNT"},
+					{"digio careers", "2.000000"},
+					{"log analysis", "1.000000"},
+				},
+				LandingPages: []ReferrerLandingPages{
+					{Domain: "google.com", TopNLandingPages: [][]string{{"LandingPage", "LandingPage_COUNT"}, {"/", "2.000000"}}},
+					{Domain: "news.ycombinator.com", TopNLandingPages: [][]string{{"LandingPage", "LandingPage_COUNT"}, {"/blog/", "2.000000"}}},
+				},
+			},
+		},
+		{
+			name: "configured internal hosts and search engines",
+			analyzer: &ReferrerAnalyzer{
+				InternalHosts: []string{"Example.net", "news.ycombinator.com"},
+				SearchEngines: []SearchEngine{{Name: "HN", Host: regexp.MustCompile(`ycombinator\.com$`), QueryParams: []string{"id"}}},
+			},
+			topN: 1,
+			want: &ReferrerAnalysis{
+				DirectCount:   2,
+				InternalCount: 4,
+				ExternalCount: 5,
+				SearchCount:   0,
+				TopNDomains: [][]string{
+					{"Domain", "Domain_COUNT"},
+					{"google.com", "2.000000"},
+				},
+				TopNSearchEngines: [][]string{{"SearchEngine", "SearchEngine_COUNT"}},
+				TopNSearchTerms:   [][]string{{"SearchTerm", "SearchTerm_COUNT"}},
+				LandingPages: []ReferrerLandingPages{
+					{Domain: "google.com", TopNLandingPages: [][]string{{"LandingPage", "LandingPage_COUNT"}, {"/", "2.000000"}}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.analyzer.GetReferrerAnalysis(logEntries, tt.siteHosts, tt.topN)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_RequestHosts(t *testing.T) {
+	logEntries := []LogEntry{
+		{URL: "http://example.net/faq/"},
+		{URL: "/docs/"},
+		{URL: "https://WWW.example.net:8443/"},
+		{URL: "http://cdn.example.net/asset.js"},
+	}
+
+	assert.Equal(t, []string{"example.net", "cdn.example.net"}, RequestHosts(logEntries))
+}
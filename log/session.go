@@ -0,0 +1,121 @@
+package log
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultSessionTimeout is the inactivity timeout used when a SessionAnalyzer has no timeout configured.
+const DefaultSessionTimeout = 30 * time.Minute
+
+// SessionAnalyzer reconstructs visitor sessions from log entries. A visitor is identified by their IP and
+// user agent, and their session ends after a period of inactivity longer than the timeout.
+type SessionAnalyzer struct {
+	Timeout time.Duration
+}
+
+type SessionAnalysis struct {
+	SessionCount int
+	// AverageLength is the average number of requests per session
+	AverageLength   float64
+	AverageDuration time.Duration
+	TopNEntryPages  [][]string
+	TopNExitPages   [][]string
+	// TopNTransitions are the most common page to page transitions within sessions, e.g. "/ -> /docs/"
+	TopNTransitions [][]string
+}
+
+type session struct {
+	entries []LogEntry
+}
+
+func (s *session) duration() time.Duration {
+	return s.entries[len(s.entries)-1].Timestamp.Sub(s.entries[0].Timestamp)
+}
+
+// GetSessionAnalysis reconstructs sessions and reports on visitor journeys. Log entries without a valid
+// timestamp are ignored.
+func (a *SessionAnalyzer) GetSessionAnalysis(logEntries []LogEntry, topN int) (*SessionAnalysis, error) {
+	sessions := a.reconstructSessions(logEntries)
+
+	var entryPages, exitPages, transitions []string
+	var totalRequests int
+	var totalDuration time.Duration
+
+	for _, s := range sessions {
+		totalRequests += len(s.entries)
+		totalDuration += s.duration()
+
+		entryPages = append(entryPages, s.entries[0].URL)
+		exitPages = append(exitPages, s.entries[len(s.entries)-1].URL)
+		for i := 1; i < len(s.entries); i++ {
+			transitions = append(transitions, s.entries[i-1].URL+" -> "+s.entries[i].URL)
+		}
+	}
+
+	sa := &SessionAnalysis{SessionCount: len(sessions)}
+
+	// an analysis of no sessions is reported, as log entries may have no valid timestamps, e.g. in a served time
+	// period
+	if len(sessions) > 0 {
+		sa.AverageLength = float64(totalRequests) / float64(len(sessions))
+		sa.AverageDuration = totalDuration / time.Duration(len(sessions))
+	}
+
+	var err error
+	if sa.TopNEntryPages, err = getTopNValues("EntryPage", entryPages, topN); err != nil {
+		return nil, err
+	}
+	if sa.TopNExitPages, err = getTopNValues("ExitPage", exitPages, topN); err != nil {
+		return nil, err
+	}
+	if sa.TopNTransitions, err = getTopNValues("Transition", transitions, topN); err != nil {
+		return nil, err
+	}
+
+	return sa, nil
+}
+
+// reconstructSessions groups log entries by visitor in time order, splitting a visitor's requests into
+// separate sessions when they are inactive for longer than the timeout.
+func (a *SessionAnalyzer) reconstructSessions(logEntries []LogEntry) []*session {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+
+	type visitor struct{ ip, userAgent string }
+	visitors := make(map[visitor][]LogEntry)
+	var order []visitor
+
+	for _, entry := range logEntries {
+		// malformed requests are not page views
+		if entry.Timestamp.IsZero() || MalformedRequest(entry) {
+			continue
+		}
+
+		v := visitor{ip: entry.IP, userAgent: entry.UserAgent}
+		if _, ok := visitors[v]; !ok {
+			order = append(order, v)
+		}
+		visitors[v] = append(visitors[v], entry)
+	}
+
+	var sessions []*session
+	for _, v := range order {
+		entries := visitors[v]
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		})
+
+		start := 0
+		for i := 1; i <= len(entries); i++ {
+			if i == len(entries) || entries[i].Timestamp.Sub(entries[i-1].Timestamp) > timeout {
+				sessions = append(sessions, &session{entries: entries[start:i]})
+				start = i
+			}
+		}
+	}
+
+	return sessions
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SessionAnalyzer_GetSessionAnalysis(t *testing.T) {
+	start := time.Date(2018, 7, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	logEntries := []LogEntry{
+		// visitor 1, two sessions separated by an hour of inactivity, out of order
+		{IP: "192.168.0.1", UserAgent: "firefox", URL: "/docs/", Timestamp: at(5)},
+		{IP: "192.168.0.1", UserAgent: "firefox", URL: "/", Timestamp: at(0)},
+		{IP: "192.168.0.1", UserAgent: "firefox", URL: "/faq/", Timestamp: at(15)},
+		{IP: "192.168.0.1", UserAgent: "firefox", URL: "/", Timestamp: at(75)},
+		// visitor 2, same IP but a different user agent
+		{IP: "192.168.0.1", UserAgent: "chrome", URL: "/", Timestamp: at(1)},
+		{IP: "192.168.0.1", UserAgent: "chrome", URL: "/docs/", Timestamp: at(3)},
+		// entries without a timestamp are ignored
+		{IP: "192.168.0.2", UserAgent: "chrome", URL: "/ignored/"},
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		entries []LogEntry
+		want    *SessionAnalysis
+		wantErr bool
+	}{
+		{
+			name:    "sessions split by inactivity timeout",
+			timeout: 30 * time.Minute,
+			entries: logEntries,
+			want: &SessionAnalysis{
+				SessionCount:    3,
+				AverageLength:   2,
+				AverageDuration: (15*time.Minute + 0 + 2*time.Minute) / 3,
+				TopNEntryPages:  [][]string{{"EntryPage", "EntryPage_COUNT"}, {"/", "3.000000"}},
+				TopNExitPages:   [][]string{{"ExitPage", "ExitPage_COUNT"}, {"/", "1.000000"}, {"/docs/", "1.000000"}},
+				TopNTransitions: [][]string{{"Transition", "Transition_COUNT"}, {"/ -> /docs/", "2.000000"}, {"/docs/ -> /faq/", "1.000000"}},
+			},
+		},
+		{
+			name:    "longer timeout joins sessions",
+			timeout: 2 * time.Hour,
+			entries: logEntries,
+			want: &SessionAnalysis{
+				SessionCount:    2,
+				AverageLength:   3,
+				AverageDuration: (75*time.Minute + 2*time.Minute) / 2,
+				TopNEntryPages:  [][]string{{"EntryPage", "EntryPage_COUNT"}, {"/", "2.000000"}},
+				TopNExitPages:   [][]string{{"ExitPage", "ExitPage_COUNT"}, {"/", "1.000000"}, {"/docs/", "1.000000"}},
+				TopNTransitions: [][]string{{"Transition", "Transition_COUNT"}, {"/ -> /docs/", "2.000000"}, {"/docs/ -> /faq/", "1.000000"}},
+			},
+		},
+		{
+			name:    "no timestamps reports no sessions",
+			entries: []LogEntry{{IP: "192.168.0.1", URL: "/"}},
+			want: &SessionAnalysis{
+				TopNEntryPages:  [][]string{{"EntryPage", "EntryPage_COUNT"}},
+				TopNExitPages:   [][]string{{"ExitPage", "ExitPage_COUNT"}},
+				TopNTransitions: [][]string{{"Transition", "Transition_COUNT"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &SessionAnalyzer{Timeout: tt.timeout}
+			got, err := a.GetSessionAnalysis(tt.entries, 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SessionAnalyzer.GetSessionAnalysis() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
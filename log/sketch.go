@@ -0,0 +1,257 @@
+package log
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// hashString returns a well distributed 64 bit hash of a string. The hash is stable across runs, so that it
+// can be stored, e.g. in a checkpoint.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	// FNV has weak avalanche in its low bits, so finalise with the murmur3 mixer
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// HyperLogLog estimates the number of distinct values added to it in a fixed amount of memory.
+type HyperLogLog struct {
+	Precision uint8
+	Registers []uint8
+}
+
+const (
+	MinHyperLogLogPrecision = 4
+	MaxHyperLogLogPrecision = 18
+)
+
+// NewHyperLogLog returns a HyperLogLog with 2^precision registers, giving a relative standard error of
+// 1.04/sqrt(2^precision), e.g. 0.81% for a precision of 14.
+func NewHyperLogLog(precision uint8) (*HyperLogLog, error) {
+	if precision < MinHyperLogLogPrecision || precision > MaxHyperLogLogPrecision {
+		return nil, fmt.Errorf("hyperloglog precision must be between %d and %d", MinHyperLogLogPrecision, MaxHyperLogLogPrecision)
+	}
+
+	return &HyperLogLog{Precision: precision, Registers: make([]uint8, 1<<precision)}, nil
+}
+
+func (h *HyperLogLog) Add(value string) {
+	x := hashString(value)
+
+	// the first p bits choose the register, the register records the longest run of leading zeros seen
+	// in the remaining bits
+	index := x >> (64 - h.Precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.Precision|1<<(h.Precision-1))) + 1
+
+	if rank > h.Registers[index] {
+		h.Registers[index] = rank
+	}
+}
+
+// Count returns the estimated number of distinct values added.
+func (h *HyperLogLog) Count() int {
+	m := float64(len(h.Registers))
+
+	sum, zeros := 0.0, 0
+	for _, r := range h.Registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := hyperLogLogAlpha(m) * m * m / sum
+
+	// use linear counting for small cardinalities, where the raw estimate is biased
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int(math.Round(estimate))
+}
+
+// RelativeError returns the relative standard error of the count.
+func (h *HyperLogLog) RelativeError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.Registers)))
+}
+
+func hyperLogLogAlpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/m)
+	}
+}
+
+// CountMinSketch estimates the frequency of values in a fixed amount of memory. Estimates never undercount,
+// and overcount by at most Epsilon times the total count with probability 1 - Delta.
+type CountMinSketch struct {
+	Epsilon float64
+	Delta   float64
+	Width   int
+	Depth   int
+	Total   int
+	Counts  [][]int
+}
+
+func NewCountMinSketch(epsilon float64, delta float64) (*CountMinSketch, error) {
+	if epsilon <= 0 || epsilon >= 1 {
+		return nil, fmt.Errorf("count-min sketch epsilon must be between 0 and 1")
+	}
+	if delta <= 0 || delta >= 1 {
+		return nil, fmt.Errorf("count-min sketch delta must be between 0 and 1")
+	}
+
+	width := int(math.Ceil(math.E / epsilon))
+	depth := int(math.Ceil(math.Log(1 / delta)))
+
+	counts := make([][]int, depth)
+	for i := range counts {
+		counts[i] = make([]int, width)
+	}
+
+	return &CountMinSketch{Epsilon: epsilon, Delta: delta, Width: width, Depth: depth, Counts: counts}, nil
+}
+
+// indexes returns the column of a value in each row, using double hashing to derive the row hashes.
+func (s *CountMinSketch) indexes(value string) []int {
+	x := hashString(value)
+	h1, h2 := x&0xffffffff, x>>32
+
+	indexes := make([]int, s.Depth)
+	for i := range indexes {
+		indexes[i] = int((h1 + uint64(i)*h2) % uint64(s.Width))
+	}
+
+	return indexes
+}
+
+func (s *CountMinSketch) Add(value string, count int) {
+	for row, col := range s.indexes(value) {
+		s.Counts[row][col] += count
+	}
+	s.Total += count
+}
+
+// Estimate returns the estimated count of a value.
+func (s *CountMinSketch) Estimate(value string) int {
+	estimate := math.MaxInt
+	for row, col := range s.indexes(value) {
+		estimate = min(estimate, s.Counts[row][col])
+	}
+
+	return estimate
+}
+
+// ErrorBound returns the maximum overcount of an estimate, with probability 1 - Delta.
+func (s *CountMinSketch) ErrorBound() int {
+	return int(math.Ceil(s.Epsilon * float64(s.Total)))
+}
+
+// SpaceSaving tracks the most frequent values in a stream using a fixed number of counters. Any value with a
+// true count greater than Total/Capacity is guaranteed to be tracked, and each counter overcounts by at most
+// its Error.
+type SpaceSaving struct {
+	Capacity int
+	Total    int
+	counters spaceSavingHeap
+	index    map[string]*SpaceSavingCounter
+}
+
+// SpaceSavingCounter is a tracked value, with a count which overcounts by at most Error.
+type SpaceSavingCounter struct {
+	Value string
+	Count int
+	Error int
+	pos   int // position in the heap
+}
+
+func NewSpaceSaving(capacity int) (*SpaceSaving, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("space-saving capacity must be at least 1")
+	}
+
+	return &SpaceSaving{Capacity: capacity, index: make(map[string]*SpaceSavingCounter)}, nil
+}
+
+func (s *SpaceSaving) Add(value string, count int) {
+	s.Total += count
+
+	if c, ok := s.index[value]; ok {
+		c.Count += count
+		heap.Fix(&s.counters, c.pos)
+		return
+	}
+
+	if len(s.counters) < s.Capacity {
+		c := &SpaceSavingCounter{Value: value, Count: count}
+		heap.Push(&s.counters, c)
+		s.index[value] = c
+		return
+	}
+
+	// replace the value with the smallest count, which becomes the error of the new value
+	c := s.counters[0]
+	delete(s.index, c.Value)
+	c.Value, c.Error, c.Count = value, c.Count, c.Count+count
+	s.index[value] = c
+	heap.Fix(&s.counters, 0)
+}
+
+// Top returns the n values with the highest counts, in descending order of count.
+func (s *SpaceSaving) Top(n int) []SpaceSavingCounter {
+	top := make([]SpaceSavingCounter, 0, len(s.counters))
+	for _, c := range s.counters {
+		top = append(top, *c)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+
+	return top[:min(n, len(top))]
+}
+
+// spaceSavingHeap is a min-heap of counters, ordered by count.
+type spaceSavingHeap []*SpaceSavingCounter
+
+func (h spaceSavingHeap) Len() int           { return len(h) }
+func (h spaceSavingHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h spaceSavingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos, h[j].pos = i, j
+}
+
+func (h *spaceSavingHeap) Push(x any) {
+	c := x.(*SpaceSavingCounter)
+	c.pos = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *spaceSavingHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipfValues returns n values drawn from a Zipf distribution over the given number of distinct values, along
+// with their exact counts, so that sketches can be compared against exact results.
+func zipfValues(n int, distinct uint64) ([]string, map[string]int) {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(42)), 1.2, 1, distinct-1)
+
+	values := make([]string, n)
+	counts := make(map[string]int)
+	for i := range values {
+		values[i] = fmt.Sprintf("/page/%d", zipf.Uint64())
+		counts[values[i]]++
+	}
+
+	return values, counts
+}
+
+func Test_HyperLogLog_Count(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision uint8
+		distinct  int
+	}{
+		{name: "small cardinality", precision: 14, distinct: 100},
+		{name: "medium cardinality", precision: 14, distinct: 10000},
+		{name: "large cardinality", precision: 14, distinct: 200000},
+		{name: "low precision", precision: 10, distinct: 50000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hll, err := NewHyperLogLog(tt.precision)
+			assert.NoError(t, err)
+
+			// add each value twice, duplicates must not be counted
+			for i := 0; i < 2*tt.distinct; i++ {
+				hll.Add(fmt.Sprintf("10.%d.%d.%d", i%tt.distinct/65536, i%tt.distinct/256%256, i%tt.distinct%256))
+			}
+
+			// allow 4 standard errors
+			tolerance := 4 * hll.RelativeError() * float64(tt.distinct)
+			assert.InDelta(t, tt.distinct, hll.Count(), tolerance)
+		})
+	}
+}
+
+func Test_NewHyperLogLog_invalidPrecision(t *testing.T) {
+	for _, precision := range []uint8{0, 3, 19} {
+		_, err := NewHyperLogLog(precision)
+		assert.Error(t, err, "precision %d", precision)
+	}
+}
+
+func Test_CountMinSketch_Estimate(t *testing.T) {
+	values, counts := zipfValues(100000, 10000)
+
+	sketch, err := NewCountMinSketch(0.001, 0.01)
+	assert.NoError(t, err)
+	for _, v := range values {
+		sketch.Add(v, 1)
+	}
+
+	bound := sketch.ErrorBound()
+	assert.Equal(t, 100, bound)
+
+	exceeded := 0
+	for value, count := range counts {
+		estimate := sketch.Estimate(value)
+		assert.GreaterOrEqual(t, estimate, count, "estimates never undercount")
+		if estimate-count > bound {
+			exceeded++
+		}
+	}
+
+	// at most delta of the estimates may exceed the error bound
+	assert.LessOrEqual(t, float64(exceeded), math.Ceil(0.01*float64(len(counts))))
+}
+
+func Test_NewCountMinSketch_invalidBounds(t *testing.T) {
+	tests := []struct {
+		epsilon float64
+		delta   float64
+	}{
+		{epsilon: 0, delta: 0.01},
+		{epsilon: 1, delta: 0.01},
+		{epsilon: 0.01, delta: 0},
+		{epsilon: 0.01, delta: 1},
+	}
+
+	for _, tt := range tests {
+		_, err := NewCountMinSketch(tt.epsilon, tt.delta)
+		assert.Error(t, err, "epsilon %v delta %v", tt.epsilon, tt.delta)
+	}
+}
+
+func Test_SpaceSaving_Top(t *testing.T) {
+	values, counts := zipfValues(100000, 10000)
+
+	capacity := 200
+	ss, err := NewSpaceSaving(capacity)
+	assert.NoError(t, err)
+	for _, v := range values {
+		ss.Add(v, 1)
+	}
+
+	// the heaviest values are all tracked, with counts within their error
+	top := ss.Top(5)
+	assert.Len(t, top, 5)
+	for i, c := range top {
+		assert.Equal(t, fmt.Sprintf("/page/%d", i), c.Value)
+		assert.GreaterOrEqual(t, c.Count, counts[c.Value])
+		assert.LessOrEqual(t, c.Count-c.Error, counts[c.Value])
+		assert.LessOrEqual(t, c.Error, len(values)/capacity)
+	}
+}
+
+func Test_SpaceSaving_fewerValuesThanCapacity(t *testing.T) {
+	ss, err := NewSpaceSaving(10)
+	assert.NoError(t, err)
+
+	for _, v := range []string{"a", "b", "a", "c", "a", "b"} {
+		ss.Add(v, 1)
+	}
+
+	assert.Equal(t, []SpaceSavingCounter{
+		{Value: "a", Count: 3},
+		{Value: "b", Count: 2},
+		{Value: "c", Count: 1},
+	}, clearHeapPositions(ss.Top(5)))
+
+	_, err = NewSpaceSaving(0)
+	assert.Error(t, err)
+}
+
+func clearHeapPositions(counters []SpaceSavingCounter) []SpaceSavingCounter {
+	for i := range counters {
+		counters[i].pos = 0
+	}
+	return counters
+}
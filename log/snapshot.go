@@ -0,0 +1,236 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot file format.
+const SnapshotVersion = 2
+
+// Snapshot holds the aggregates behind a LogAnalysis, so that analyses of separate logs, e.g. daily logs, can
+// be merged into an accurate combined analysis without re-parsing the logs. Counts are exact, so unique IPs
+// are counted across snapshots rather than summed, and a snapshot grows with the number of distinct URLs and
+// IPs.
+type Snapshot struct {
+	Version      int
+	Config       SnapshotConfig
+	From         time.Time // the earliest log entry
+	To           time.Time // the latest log entry
+	RequestCount int
+	ErrorCount   int
+	TotalBytes   int
+	URLCounts    map[string]int
+	IPCounts     map[string]int
+}
+
+// SnapshotConfig is the config of the SnapshotAnalyzer a snapshot was aggregated with. URLs are counted once
+// normalised, so snapshots with different configs cannot be merged.
+type SnapshotConfig struct {
+	URLNormalisation URLNormaliserConfig
+	IPv4PrefixLen    int
+	IPv6PrefixLen    int
+}
+
+// Equal reports whether snapshots aggregated with the two configs can be merged.
+func (c SnapshotConfig) Equal(other SnapshotConfig) bool {
+	return c.URLNormalisation.Equal(other.URLNormalisation) &&
+		c.IPv4PrefixLen == other.IPv4PrefixLen && c.IPv6PrefixLen == other.IPv6PrefixLen
+}
+
+// SnapshotAnalyzer aggregates log entries into snapshots, and analyses snapshots.
+type SnapshotAnalyzer struct {
+	// URLNormaliser is applied to URLs as they are aggregated, URLs are aggregated verbatim when nil.
+	URLNormaliser *URLNormaliser
+	// IPv4PrefixLen and IPv6PrefixLen group the most active IPs by network prefix, as for the CombinedLogAnalyzer
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+}
+
+func newSnapshot(config SnapshotConfig) *Snapshot {
+	return &Snapshot{Version: SnapshotVersion, Config: config, URLCounts: make(map[string]int), IPCounts: make(map[string]int)}
+}
+
+// Config returns the config recorded in the snapshots of the analyzer.
+func (a *SnapshotAnalyzer) Config() SnapshotConfig {
+	config := SnapshotConfig{IPv4PrefixLen: a.IPv4PrefixLen, IPv6PrefixLen: a.IPv6PrefixLen}
+	if a.URLNormaliser != nil {
+		config.URLNormalisation = a.URLNormaliser.Config()
+	}
+
+	return config
+}
+
+// NewSnapshot aggregates log entries into a snapshot.
+func (a *SnapshotAnalyzer) NewSnapshot(logEntries []LogEntry) *Snapshot {
+	s := newSnapshot(a.Config())
+	a.AddLogEntries(s, logEntries)
+
+	return s
+}
+
+// AddLogEntries adds log entries to the aggregates of an existing snapshot.
+func (a *SnapshotAnalyzer) AddLogEntries(s *Snapshot, logEntries []LogEntry) {
+	for _, entry := range logEntries {
+		url := entry.URL
+		if a.URLNormaliser != nil {
+			url = a.URLNormaliser.Normalise(url)
+		}
+
+		s.RequestCount++
+		s.TotalBytes += entry.Size
+		if entry.StatusCode >= 400 {
+			s.ErrorCount++
+		}
+		if !MalformedRequest(entry) {
+			s.URLCounts[url]++
+		}
+		s.IPCounts[entry.IP]++
+		s.extendTimeRange(entry.Timestamp, entry.Timestamp)
+	}
+}
+
+// Merge adds the aggregates of another snapshot to this snapshot, returning an error when the snapshots were
+// aggregated with different configs.
+func (s *Snapshot) Merge(other *Snapshot) error {
+	if !s.Config.Equal(other.Config) {
+		return fmt.Errorf("snapshots were saved with different url-normalisation or IP prefix length config")
+	}
+
+	s.RequestCount += other.RequestCount
+	s.ErrorCount += other.ErrorCount
+	s.TotalBytes += other.TotalBytes
+
+	for url, count := range other.URLCounts {
+		s.URLCounts[url] += count
+	}
+	for ip, count := range other.IPCounts {
+		s.IPCounts[ip] += count
+	}
+
+	s.extendTimeRange(other.From, other.To)
+
+	return nil
+}
+
+func (s *Snapshot) extendTimeRange(from, to time.Time) {
+	if !from.IsZero() && (s.From.IsZero() || from.Before(s.From)) {
+		s.From = from
+	}
+	if !to.IsZero() && (s.To.IsZero() || to.After(s.To)) {
+		s.To = to
+	}
+}
+
+// GetLogAnalysis analyses the aggregates of a snapshot, giving the same results as a CombinedLogAnalyzer with
+// ClampTopN set would for the log entries in the snapshot. The snapshot must have been saved with the same
+// config as the analyzer.
+func (a *SnapshotAnalyzer) GetLogAnalysis(s *Snapshot, topN int) (*LogAnalysis, error) {
+	if !s.Config.Equal(a.Config()) {
+		return nil, fmt.Errorf("snapshot was saved with different url-normalisation or IP prefix length config")
+	}
+	if s.RequestCount == 0 {
+		return nil, fmt.Errorf("snapshot has no log entries")
+	}
+
+	ipColumn, activeIPCounts := "IP", s.IPCounts
+	if a.IPv4PrefixLen > 0 || a.IPv6PrefixLen > 0 {
+		ipColumn, activeIPCounts = "IPPrefix", make(map[string]int)
+		for ip, count := range s.IPCounts {
+			activeIPCounts[IPPrefix(ip, a.IPv4PrefixLen, a.IPv6PrefixLen)] += count
+		}
+	}
+
+	return &LogAnalysis{
+		RequestCount:        s.RequestCount,
+		ErrorRate:           float64(s.ErrorCount) / float64(s.RequestCount),
+		TotalBytes:          s.TotalBytes,
+		UniqueIPCount:       len(s.IPCounts),
+		TopNMostVisitedURLs: topNCountRecords("URL", s.URLCounts, topN),
+		TopNMostActiveIPs:   topNCountRecords(ipColumn, activeIPCounts, topN),
+	}, nil
+}
+
+// topNCountRecords returns the n values with the highest counts, in the same records format as the
+// CombinedLogAnalyzer, with ties ordered by value.
+func topNCountRecords(colName string, counts map[string]int, n int) [][]string {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	records := [][]string{{colName, colName + "_COUNT"}}
+	for _, value := range values[:min(n, len(values))] {
+		records = append(records, []string{value, fmt.Sprintf("%f", float64(counts[value]))})
+	}
+
+	return records
+}
+
+// WriteSnapshot writes a snapshot as gzip compressed JSON.
+func WriteSnapshot(w io.Writer, s *Snapshot) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(s); err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	return gz.Close()
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	s := newSnapshot(SnapshotConfig{})
+	if err := json.NewDecoder(gz).Decode(s); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %w", err)
+	}
+
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", s.Version, SnapshotVersion)
+	}
+
+	return s, nil
+}
+
+// SaveSnapshot writes a snapshot to a file.
+func SaveSnapshot(path string, s *Snapshot) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteSnapshot(file, s); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// LoadSnapshot reads a snapshot from a file.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSnapshot(file)
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SnapshotAnalyzer_GetLogAnalysis(t *testing.T) {
+	monday := time.Date(2018, 7, 9, 10, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+
+	mondayEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "http://example.net/faq/", StatusCode: 200, Size: 100, Timestamp: monday},
+		{IP: "168.41.191.41", URL: "/faq/", StatusCode: 404, Size: 10, Timestamp: monday.Add(time.Hour)},
+		{IP: "177.71.128.21", URL: "/", StatusCode: 200, Size: 50, Timestamp: monday.Add(2 * time.Hour)},
+	}
+	tuesdayEntries := []LogEntry{
+		{IP: "168.41.191.40", URL: "/", StatusCode: 200, Size: 50, Timestamp: tuesday},
+		{IP: "168.41.191.40", URL: "/docs/", StatusCode: 500, Size: 0, Timestamp: tuesday.Add(time.Hour)},
+		{IP: "72.44.32.10", URL: "/", StatusCode: 200, Size: 50, Timestamp: tuesday.Add(2 * time.Hour)},
+	}
+
+	normaliser, err := NewURLNormaliser(URLNormaliserConfig{StripHost: true})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		analyzer *SnapshotAnalyzer
+	}{
+		{
+			name:     "exact",
+			analyzer: &SnapshotAnalyzer{},
+		},
+		{
+			name:     "url normalisation",
+			analyzer: &SnapshotAnalyzer{URLNormaliser: normaliser},
+		},
+		{
+			name:     "ip prefix grouping",
+			analyzer: &SnapshotAnalyzer{URLNormaliser: normaliser, IPv4PrefixLen: 24},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := tt.analyzer.NewSnapshot(mondayEntries)
+			assert.NoError(t, merged.Merge(tt.analyzer.NewSnapshot(tuesdayEntries)))
+
+			got, err := tt.analyzer.GetLogAnalysis(merged, 2)
+			assert.NoError(t, err)
+
+			// merging snapshots gives the same results as analysing all the log entries together
+			want, err := (&CombinedLogAnalyzer{
+				URLNormaliser: tt.analyzer.URLNormaliser,
+				IPv4PrefixLen: tt.analyzer.IPv4PrefixLen,
+				IPv6PrefixLen: tt.analyzer.IPv6PrefixLen,
+			}).GetLogAnalysis(append(mondayEntries, tuesdayEntries...), 2)
+			assert.NoError(t, err)
+
+			assert.Equal(t, want, got)
+			assert.Equal(t, 4, got.UniqueIPCount)
+			assert.Equal(t, monday, merged.From)
+			assert.Equal(t, tuesday.Add(2*time.Hour), merged.To)
+		})
+	}
+}
+
+func Test_Snapshot_Merge_differentConfig(t *testing.T) {
+	logEntries := []LogEntry{{IP: "168.41.191.40", URL: "/faq/?q=1", StatusCode: 200}}
+
+	normaliser, err := NewURLNormaliser(URLNormaliserConfig{DropQuery: true})
+	assert.NoError(t, err)
+	normalised := &SnapshotAnalyzer{URLNormaliser: normaliser}
+	verbatim := &SnapshotAnalyzer{}
+
+	// snapshots keyed by differently normalised URLs are not merged
+	merged := normalised.NewSnapshot(logEntries)
+	assert.EqualError(t, merged.Merge(verbatim.NewSnapshot(logEntries)),
+		"snapshots were saved with different url-normalisation or IP prefix length config")
+	assert.EqualError(t, merged.Merge((&SnapshotAnalyzer{URLNormaliser: normaliser, IPv4PrefixLen: 24}).NewSnapshot(logEntries)),
+		"snapshots were saved with different url-normalisation or IP prefix length config")
+	assert.Equal(t, 1, merged.RequestCount)
+
+	_, err = verbatim.GetLogAnalysis(merged, 1)
+	assert.EqualError(t, err, "snapshot was saved with different url-normalisation or IP prefix length config")
+
+	// a normaliser with no options set has the same config as no normaliser
+	unnormalised, err := NewURLNormaliser(URLNormaliserConfig{KeepQueryParams: []string{}})
+	assert.NoError(t, err)
+	merged = verbatim.NewSnapshot(logEntries)
+	assert.NoError(t, merged.Merge((&SnapshotAnalyzer{URLNormaliser: unnormalised}).NewSnapshot(logEntries)))
+}
+
+func Test_SnapshotAnalyzer_GetLogAnalysis_empty(t *testing.T) {
+	analyzer := &SnapshotAnalyzer{}
+
+	_, err := analyzer.GetLogAnalysis(analyzer.NewSnapshot(nil), 3)
+	assert.EqualError(t, err, "snapshot has no log entries")
+}
+
+func Test_ReadSnapshot(t *testing.T) {
+	snapshot := (&SnapshotAnalyzer{}).NewSnapshot([]LogEntry{
+		{IP: "168.41.191.40", URL: "/faq/", StatusCode: 200, Size: 100, Timestamp: time.Date(2018, 7, 9, 10, 0, 0, 0, time.UTC)},
+		{IP: "177.71.128.21", URL: "/", StatusCode: 404, Size: 10},
+	})
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSnapshot(&buf, snapshot))
+
+	got, err := ReadSnapshot(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, got)
+
+	_, err = ReadSnapshot(bytes.NewBufferString("not a snapshot"))
+	assert.ErrorContains(t, err, "error reading snapshot")
+
+	snapshot.Version = SnapshotVersion + 1
+	buf.Reset()
+	assert.NoError(t, WriteSnapshot(&buf, snapshot))
+
+	_, err = ReadSnapshot(&buf)
+	assert.ErrorContains(t, err, "unsupported snapshot version")
+}
@@ -0,0 +1,202 @@
+package log
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// URLNormaliserConfig configures how URLs are normalised before they are grouped for analysis.
+type URLNormaliserConfig struct {
+	// StripHost removes the scheme and host from absolute URLs, so http://example.net/faq/ becomes /faq/.
+	StripHost bool `mapstructure:"strip-host"`
+	// DropQuery removes query strings, except for parameters listed in KeepQueryParams.
+	DropQuery       bool     `mapstructure:"drop-query"`
+	KeepQueryParams []string `mapstructure:"keep-query-params"`
+	// FoldTrailingSlash removes trailing slashes, so /faq/ and /faq are grouped together.
+	FoldTrailingSlash bool `mapstructure:"fold-trailing-slash"`
+	Lowercase         bool `mapstructure:"lowercase"`
+	// TemplateIDs replaces path segments which look like IDs, i.e. numbers, UUIDs and long hex strings, with
+	// {id}, so /users/1 and /users/2 are grouped together as /users/{id}.
+	TemplateIDs bool `mapstructure:"template-ids"`
+	// Patterns replace matching URL paths with the pattern itself, e.g. /users/{id} groups /users/1 and /users/2.
+	// A * or {name} segment matches any single path segment, and a final ** segment matches any remaining segments.
+	Patterns []string `mapstructure:"patterns"`
+}
+
+type URLNormaliser struct {
+	config   URLNormaliserConfig
+	patterns []urlPattern
+}
+
+type urlPattern struct {
+	template string
+	segments []string
+}
+
+// NewURLNormaliser validates the config and compiles the URL patterns.
+func NewURLNormaliser(config URLNormaliserConfig) (*URLNormaliser, error) {
+	n := &URLNormaliser{config: config}
+
+	for _, pattern := range config.Patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid URL pattern %q: must start with /", pattern)
+		}
+
+		template := n.normalisePath(pattern)
+		segments := strings.Split(template, "/")
+		if i := slices.Index(segments, "**"); i != -1 && i != len(segments)-1 {
+			return nil, fmt.Errorf("invalid URL pattern %q: ** must be the last segment", pattern)
+		}
+
+		n.patterns = append(n.patterns, urlPattern{template: template, segments: segments})
+	}
+
+	return n, nil
+}
+
+// Config returns the config the normaliser was created with.
+func (n *URLNormaliser) Config() URLNormaliserConfig {
+	return n.config
+}
+
+// enabled reports whether any normalisation option is enabled. Query parameters are only kept when dropping
+// the query.
+func (n *URLNormaliser) enabled() bool {
+	c := n.config
+	return c.StripHost || c.DropQuery || c.FoldTrailingSlash || c.Lowercase || c.TemplateIDs || len(c.Patterns) > 0
+}
+
+// Equal reports whether two configs normalise URLs in the same way.
+func (c URLNormaliserConfig) Equal(other URLNormaliserConfig) bool {
+	return c.StripHost == other.StripHost && c.DropQuery == other.DropQuery &&
+		slices.Equal(c.KeepQueryParams, other.KeepQueryParams) && c.FoldTrailingSlash == other.FoldTrailingSlash &&
+		c.Lowercase == other.Lowercase && c.TemplateIDs == other.TemplateIDs && slices.Equal(c.Patterns, other.Patterns)
+}
+
+// Normalise returns the normalised form of a request URL. URLs are returned unchanged when no options are
+// enabled, so they are counted as logged, or when they cannot be parsed.
+func (n *URLNormaliser) Normalise(rawURL string) string {
+	if !n.enabled() {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	path := n.normalisePath(u.EscapedPath())
+	if n.config.TemplateIDs {
+		path = templateIDs(path)
+	}
+	for _, pattern := range n.patterns {
+		if pattern.match(path) {
+			path = pattern.template
+			break
+		}
+	}
+
+	var sb strings.Builder
+	if !n.config.StripHost && u.Host != "" {
+		sb.WriteString(u.Scheme + "://" + u.Host)
+	}
+	sb.WriteString(path)
+	if query := n.normaliseQuery(u.Query(), u.RawQuery); query != "" {
+		sb.WriteString("?" + query)
+	}
+
+	if n.config.Lowercase {
+		return strings.ToLower(sb.String())
+	}
+
+	return sb.String()
+}
+
+func (n *URLNormaliser) normalisePath(path string) string {
+	if path == "" {
+		path = "/"
+	}
+
+	if n.config.Lowercase {
+		path = strings.ToLower(path)
+	}
+
+	if n.config.FoldTrailingSlash && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	return path
+}
+
+// idSegment matches path segments which look like IDs: numbers, UUIDs and hex strings of at least 16 digits.
+var idSegment = regexp.MustCompile(`^(?:[0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// templateIDs replaces the segments of a path which look like IDs with {id}.
+func templateIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (n *URLNormaliser) normaliseQuery(query url.Values, rawQuery string) string {
+	if !n.config.DropQuery {
+		return rawQuery
+	}
+
+	kept := url.Values{}
+	for _, param := range n.config.KeepQueryParams {
+		if values, ok := query[param]; ok {
+			kept[param] = values
+		}
+	}
+
+	return kept.Encode()
+}
+
+// match reports whether a normalised URL path matches the pattern.
+func (p urlPattern) match(path string) bool {
+	segments := strings.Split(path, "/")
+
+	for i, patternSegment := range p.segments {
+		if patternSegment == "**" {
+			return true
+		}
+
+		if i >= len(segments) {
+			return false
+		}
+
+		isWildcard := patternSegment == "*" || (strings.HasPrefix(patternSegment, "{") && strings.HasSuffix(patternSegment, "}"))
+		if isWildcard && segments[i] == "" {
+			return false
+		}
+
+		if !isWildcard && patternSegment != segments[i] {
+			return false
+		}
+	}
+
+	return len(segments) == len(p.segments)
+}
+
+// normaliseURLs returns a copy of the log entries with their URLs normalised.
+func normaliseURLs(logEntries []LogEntry, normaliser *URLNormaliser) []LogEntry {
+	normalised := make([]LogEntry, len(logEntries))
+	for i, entry := range logEntries {
+		entry.URL = normaliser.Normalise(entry.URL)
+		normalised[i] = entry
+	}
+
+	return normalised
+}
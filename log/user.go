@@ -0,0 +1,130 @@
+package log
+
+import (
+	"sort"
+	"time"
+)
+
+// UserAnalyzer reports on the activity of authenticated users.
+type UserAnalyzer struct{}
+
+type UserAnalysis struct {
+	// AuthenticatedCount and AnonymousCount split requests by whether they identify a user
+	AuthenticatedCount int
+	AnonymousCount     int
+	// AuthenticatedShare is the proportion of requests by authenticated users
+	AuthenticatedShare float64
+	UserCount          int
+	// TopNUsers are the authenticated users with the most requests
+	TopNUsers []UserActivity
+}
+
+// UserActivity summarises the requests of an authenticated user.
+type UserActivity struct {
+	User         string
+	RequestCount int
+	// URLCount is the number of distinct URLs requested
+	URLCount int
+	// FirstSeen and LastSeen are the times of the user's first and last requests with a valid timestamp
+	FirstSeen time.Time
+	LastSeen  time.Time
+	TopNURLs  [][]string
+}
+
+// User returns the authenticated user of a log entry: the UserID of HTTP authentication, or the Identity
+// reported by identd when there is no UserID. It returns "" for anonymous requests.
+func User(entry LogEntry) string {
+	for _, user := range []string{entry.UserID, entry.Identity} {
+		if user != "" && user != "-" {
+			return user
+		}
+	}
+
+	return ""
+}
+
+// GetUserAnalysis reports on the requests of each authenticated user, and the share of requests which are
+// authenticated.
+func (a *UserAnalyzer) GetUserAnalysis(logEntries []LogEntry, topN int) (*UserAnalysis, error) {
+	ua := &UserAnalysis{}
+
+	users := make(map[string]*UserActivity)
+	urls := make(map[string][]string)
+
+	for _, entry := range logEntries {
+		user := User(entry)
+		if user == "" {
+			ua.AnonymousCount++
+			continue
+		}
+
+		ua.AuthenticatedCount++
+
+		activity, ok := users[user]
+		if !ok {
+			activity = &UserActivity{User: user}
+			users[user] = activity
+		}
+
+		activity.RequestCount++
+		if !MalformedRequest(entry) {
+			urls[user] = append(urls[user], entry.URL)
+		}
+
+		if t := entry.Timestamp; !t.IsZero() {
+			if activity.FirstSeen.IsZero() || t.Before(activity.FirstSeen) {
+				activity.FirstSeen = t
+			}
+			if t.After(activity.LastSeen) {
+				activity.LastSeen = t
+			}
+		}
+	}
+
+	if len(logEntries) > 0 {
+		ua.AuthenticatedShare = float64(ua.AuthenticatedCount) / float64(len(logEntries))
+	}
+	ua.UserCount = len(users)
+
+	ranked := make([]*UserActivity, 0, len(users))
+	for _, activity := range users {
+		ranked = append(ranked, activity)
+	}
+
+	// rank by requests, then by user to make output deterministic
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].RequestCount != ranked[j].RequestCount {
+			return ranked[i].RequestCount > ranked[j].RequestCount
+		}
+		return ranked[i].User < ranked[j].User
+	})
+
+	for _, activity := range ranked[:min(topN, len(ranked))] {
+		distinct := make(map[string]bool)
+		for _, url := range urls[activity.User] {
+			distinct[url] = true
+		}
+		activity.URLCount = len(distinct)
+
+		var err error
+		if activity.TopNURLs, err = getTopNValues("URL", urls[activity.User], topN); err != nil {
+			return nil, err
+		}
+
+		ua.TopNUsers = append(ua.TopNUsers, *activity)
+	}
+
+	return ua, nil
+}
+
+// UserRequests returns the log entries of the requests made by an authenticated user, in log order.
+func UserRequests(logEntries []LogEntry, user string) []LogEntry {
+	var requests []LogEntry
+	for _, entry := range logEntries {
+		if User(entry) == user {
+			requests = append(requests, entry)
+		}
+	}
+
+	return requests
+}
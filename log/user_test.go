@@ -0,0 +1,92 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserAnalyzer_GetUserAnalysis(t *testing.T) {
+	start := time.Date(2018, 7, 11, 17, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	logEntries := []LogEntry{
+		{Identity: "-", UserID: "admin", URL: "/asset.js", Timestamp: at(5)},
+		{Identity: "-", UserID: "admin", URL: "/hosting/", Timestamp: at(1)},
+		{Identity: "-", UserID: "admin", URL: "/hosting/", Timestamp: at(9)},
+		{Identity: "-", UserID: "-", URL: "/"},
+		{Identity: "-", UserID: "-", URL: "/faq/"},
+		// identd identities are used when there is no user id
+		{Identity: "jane", UserID: "-", URL: "/docs/"},
+		// entries without a timestamp count, but are not seen
+		{Identity: "-", UserID: "bob", URL: "/docs/"},
+		{Identity: "-", UserID: "", URL: "/"},
+	}
+
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		topN    int
+		want    *UserAnalysis
+	}{
+		{
+			name:    "users",
+			entries: logEntries,
+			topN:    2,
+			want: &UserAnalysis{
+				AuthenticatedCount: 5,
+				AnonymousCount:     3,
+				AuthenticatedShare: 0.625,
+				UserCount:          3,
+				TopNUsers: []UserActivity{
+					{
+						User:         "admin",
+						RequestCount: 3,
+						URLCount:     2,
+						FirstSeen:    at(1),
+						LastSeen:     at(9),
+						TopNURLs:     [][]string{{"URL", "URL_COUNT"}, {"/hosting/", "2.000000"}, {"/asset.js", "1.000000"}},
+					},
+					{
+						User:         "bob",
+						RequestCount: 1,
+						URLCount:     1,
+						TopNURLs:     [][]string{{"URL", "URL_COUNT"}, {"/docs/", "1.000000"}},
+					},
+				},
+			},
+		},
+		{
+			name:    "no authenticated users",
+			entries: logEntries[3:5],
+			topN:    2,
+			want: &UserAnalysis{
+				AnonymousCount: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&UserAnalyzer{}).GetUserAnalysis(tt.entries, tt.topN)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_UserRequests(t *testing.T) {
+	logEntries := []LogEntry{
+		{UserID: "admin", URL: "/asset.js"},
+		{UserID: "-", URL: "/"},
+		{Identity: "admin", UserID: "-", URL: "/docs/"},
+		{Identity: "jane", UserID: "admin", URL: "/hosting/"},
+	}
+
+	got := UserRequests(logEntries, "admin")
+	assert.Equal(t, []LogEntry{logEntries[0], logEntries[2], logEntries[3]}, got)
+	assert.Empty(t, UserRequests(logEntries, "bob"))
+}
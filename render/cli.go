@@ -2,54 +2,434 @@ package render
 
 import (
 	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
-	"github.com/spf13/viper"
 
 	"github.com/ryannortham/digio-task/log"
 )
 
-func PrintAnalysisResults(logAnalysis *log.LogAnalysis) {
-	printDigioLogo()
+// TableOptions adapt table output to the terminal it is written to.
+type TableOptions struct {
+	// NoBanner omits the Digio logo
+	NoBanner bool
+	// Quiet omits the logo and the list of parse errors and warnings, leaving only their summary
+	Quiet bool
+	// Width truncates long values, such as URLs and user agents, so that tables fit in a terminal of this many
+	// columns. A width of 0 disables truncation.
+	Width int
+}
+
+// TableRenderer renders reports as coloured tables for the terminal.
+type TableRenderer struct {
+	TableOptions
+}
+
+// RenderAnalysis writes the analysis results as tables, followed by a summary of any log lines which were
+// omitted or flagged during parsing.
+func (r *TableRenderer) RenderAnalysis(w io.Writer, meta ReportMetadata, logAnalysis *log.LogAnalysis, report *log.ParseReport) error {
+	ew := &errWriter{w: w}
+
+	if !r.NoBanner && !r.Quiet {
+		printDigioLogo(ew)
+	}
+
+	fmt.Fprintf(ew, "Analysis Results of Log File: %s\n\n", meta.LogFile)
+
+	fmt.Fprintf(ew, "Unique IP addresses: %d\n\n", logAnalysis.UniqueIPCount)
+
+	if a := logAnalysis.Approximation; a != nil {
+		fmt.Fprintf(ew, "Approximate results: unique IPs ±%.2f%%, counts overestimated by at most %d with %.0f%% confidence\n\n",
+			a.UniqueCountError*100, a.CountErrorBound, a.Confidence*100)
+	}
 
-	fmt.Printf("Analysis Results of Log File: %s\n\n", viper.GetString("log-file"))
+	fmt.Fprintf(ew, "Top %d most visited URLs:\n", meta.TopN)
+	printTable(ew, r.Width, logAnalysis.TopNMostVisitedURLs)
 
-	fmt.Printf("Unique IP addresses: %d\n\n", logAnalysis.UniqueIPCount)
+	fmt.Fprintf(ew, "Top %d most active IPs:\n", meta.TopN)
+	printTable(ew, r.Width, logAnalysis.TopNMostActiveIPs)
 
-	fmt.Printf("Top %d most visited URLs:\n", viper.GetInt("top-n"))
-	printTable(logAnalysis.TopNMostVisitedURLs)
+	if logAnalysis.TopNCountries != nil {
+		fmt.Fprintf(ew, "Top %d countries:\n", meta.TopN)
+		printTable(ew, r.Width, logAnalysis.TopNCountries)
+	}
+
+	if logAnalysis.TopNASNs != nil {
+		fmt.Fprintf(ew, "Top %d networks:\n", meta.TopN)
+		printTable(ew, r.Width, logAnalysis.TopNASNs)
+	}
+
+	for _, values := range logAnalysis.GroupedBy {
+		fmt.Fprintf(ew, "Top %d %s values:\n", meta.TopN, values.Field)
+		printTable(ew, r.Width, values.TopNValues)
+	}
 
-	fmt.Printf("Top %d most active IPs:\n", viper.GetInt("top-n"))
-	printTable(logAnalysis.TopNMostActiveIPs)
+	if logAnalysis.Sessions != nil {
+		printSessionAnalysis(ew, r.Width, meta.TopN, logAnalysis.Sessions)
+	}
+
+	if logAnalysis.Methods != nil {
+		printMethodAnalysis(ew, r.Width, meta.TopN, logAnalysis.Methods)
+	}
+
+	if logAnalysis.Users != nil {
+		printUserAnalysis(ew, r.Width, meta.TopN, logAnalysis.Users)
+	}
+
+	if logAnalysis.Referrers != nil {
+		printReferrerAnalysis(ew, r.Width, meta.TopN, logAnalysis.Referrers)
+	}
+
+	if logAnalysis.Latency != nil {
+		printLatencyAnalysis(ew, r.Width, meta.TopN, logAnalysis.Latency)
+	}
+
+	if logAnalysis.Findings != nil {
+		printFindings(ew, r.Width, logAnalysis.Findings)
+	}
+
+	printParseReport(ew, r.Width, r.Quiet, report)
+
+	return ew.err
 }
 
-func printTable(results [][]string) {
+func printFindings(w io.Writer, width int, findings []log.Finding) {
+	fmt.Fprintf(w, "Anomalies found: %d\n", len(findings))
+	if len(findings) == 0 {
+		fmt.Fprintln(w)
+		return
+	}
+
+	rows := [][]string{{"Severity", "Kind", "Subject", "Detail"}}
+	for _, f := range findings {
+		rows = append(rows, []string{f.Severity.String(), f.Kind, f.Subject, f.Detail})
+	}
+	truncateColumn(rows, 3, width)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	tbl := table.New(rows[0][0], rows[0][1], rows[0][2], rows[0][3]).WithWriter(w).WithWidthFunc(displayWidth)
+	tbl.WithHeaderFormatter(headerFmt)
+
+	severityColors := map[log.Severity]*color.Color{
+		log.SeverityHigh:   color.New(color.FgHiRed),
+		log.SeverityMedium: color.New(color.FgHiYellow),
+		log.SeverityLow:    color.New(color.FgHiBlue),
+	}
+
+	for i, f := range findings {
+		row := rows[i+1]
+		if c, ok := severityColors[f.Severity]; ok {
+			row[0] = c.Sprint(row[0])
+		}
+
+		tbl.AddRow(row[0], row[1], row[2], row[3])
+	}
+
+	tbl.Print()
+	fmt.Fprintln(w)
+}
+
+func printSessionAnalysis(w io.Writer, width int, topN int, sessions *log.SessionAnalysis) {
+	if sessions.SessionCount == 0 {
+		fmt.Fprint(w, "No sessions found, log entries have no valid timestamps\n\n")
+		return
+	}
+
+	fmt.Fprintf(w, "Sessions: %d\n", sessions.SessionCount)
+	fmt.Fprintf(w, "Average session length: %.1f requests\n", sessions.AverageLength)
+	fmt.Fprintf(w, "Average session duration: %s\n\n", sessions.AverageDuration.Round(time.Second))
+
+	fmt.Fprintf(w, "Top %d entry pages:\n", topN)
+	printTable(w, width, sessions.TopNEntryPages)
+
+	fmt.Fprintf(w, "Top %d exit pages:\n", topN)
+	printTable(w, width, sessions.TopNExitPages)
+
+	fmt.Fprintf(w, "Top %d page transitions:\n", topN)
+	printTable(w, width, sessions.TopNTransitions)
+}
+
+func printMethodAnalysis(w io.Writer, width int, topN int, methods *log.MethodAnalysis) {
+	if methods.MalformedCount > 0 {
+		fmt.Fprintf(w, "Malformed request lines: %d, listed as parse warnings\n\n", methods.MalformedCount)
+	}
+
+	fmt.Fprintf(w, "Top %d methods:\n", topN)
+	printTable(w, width, methods.TopNMethods)
+
+	fmt.Fprintf(w, "Top %d protocols:\n", topN)
+	printTable(w, width, methods.TopNProtocols)
+
+	for _, urls := range methods.URLsByMethod {
+		fmt.Fprintf(w, "Top %d %s URLs:\n", topN, urls.Method)
+		printTable(w, width, urls.TopNURLs)
+	}
+}
+
+func printUserAnalysis(w io.Writer, width int, topN int, users *log.UserAnalysis) {
+	fmt.Fprintf(w, "Authenticated requests: %d of %d (%.1f%%), by %d users\n\n",
+		users.AuthenticatedCount, users.AuthenticatedCount+users.AnonymousCount, users.AuthenticatedShare*100, users.UserCount)
+	if len(users.TopNUsers) == 0 {
+		return
+	}
+
+	rows := [][]string{{"User", "Requests", "URLs", "FirstSeen", "LastSeen"}}
+	for _, u := range users.TopNUsers {
+		rows = append(rows, []string{u.User, strconv.Itoa(u.RequestCount), strconv.Itoa(u.URLCount), formatSeen(u.FirstSeen), formatSeen(u.LastSeen)})
+	}
+	truncateColumn(rows, 0, width)
+
+	fmt.Fprintf(w, "Top %d most active users:\n", topN)
 	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
-	tbl := table.New(results[0][0], results[0][1])
+	tbl := table.New(rows[0][0], rows[0][1], rows[0][2], rows[0][3], rows[0][4]).WithWriter(w)
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for i, row := range results {
-		if i == 0 {
-			continue
+	for _, row := range rows[1:] {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4])
+	}
+
+	tbl.Print()
+	fmt.Fprintln(w)
+
+	for _, u := range users.TopNUsers {
+		fmt.Fprintf(w, "Top %d URLs of %s:\n", topN, u.User)
+		printTable(w, width, u.TopNURLs)
+	}
+}
+
+// formatSeen formats the time a user was seen, or "-" for users with no valid timestamps.
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func printLatencyAnalysis(w io.Writer, width int, topN int, latency *log.LatencyAnalysis) {
+	if latency.RequestCount == 0 {
+		fmt.Fprint(w, "No response times found, check the response-time field is configured\n\n")
+		return
+	}
+
+	fmt.Fprintf(w, "Response times of %d requests: p50 %s, p90 %s, p99 %s\n\n",
+		latency.RequestCount, formatLatency(latency.P50), formatLatency(latency.P90), formatLatency(latency.P99))
+
+	urlRows := func(urls []log.URLLatency) [][]string {
+		rows := [][]string{{"URL", "Requests", "P50", "P90", "P99"}}
+		for _, u := range urls {
+			rows = append(rows, append([]string{u.URL, strconv.Itoa(u.RequestCount)}, latencyColumns(u.Latency)...))
+		}
+		return rows
+	}
+
+	fmt.Fprintf(w, "Response times of the top %d most visited URLs:\n", topN)
+	printLatencyTable(w, width, urlRows(latency.TopNURLs))
+
+	fmt.Fprintf(w, "Top %d slowest URLs:\n", topN)
+	printLatencyTable(w, width, urlRows(latency.TopNSlowestURLs))
+
+	if len(latency.OverTime) == 0 {
+		return
+	}
+
+	rows := [][]string{{"Start", "Requests", "P50", "P90", "P99"}}
+	for _, i := range latency.OverTime {
+		rows = append(rows, append([]string{i.Start.Format(time.RFC3339), strconv.Itoa(i.RequestCount)}, latencyColumns(i.Latency)...))
+	}
+
+	fmt.Fprintln(w, "Response times over time:")
+	printLatencyTable(w, width, rows)
+}
+
+// printLatencyTable prints a table of request counts and response time percentiles, truncating the first
+// column to fit the width.
+func printLatencyTable(w io.Writer, width int, rows [][]string) {
+	truncateColumn(rows, 0, width)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New(rows[0][0], rows[0][1], rows[0][2], rows[0][3], rows[0][4]).WithWriter(w)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range rows[1:] {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4])
+	}
+
+	tbl.Print()
+	fmt.Fprintln(w)
+}
+
+func latencyColumns(l log.Latency) []string {
+	return []string{formatLatency(l.P50), formatLatency(l.P90), formatLatency(l.P99)}
+}
+
+// formatLatency rounds a response time for display, e.g. 1.23s, 45.68ms or 789µs.
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+func printReferrerAnalysis(w io.Writer, width int, topN int, referrers *log.ReferrerAnalysis) {
+	fmt.Fprintf(w, "Traffic sources: %d direct, %d internal, %d external (%d from search engines)\n\n",
+		referrers.DirectCount, referrers.InternalCount, referrers.ExternalCount, referrers.SearchCount)
+
+	fmt.Fprintf(w, "Top %d referring domains:\n", topN)
+	printTable(w, width, referrers.TopNDomains)
+
+	fmt.Fprintf(w, "Top %d search engines:\n", topN)
+	printTable(w, width, referrers.TopNSearchEngines)
+
+	fmt.Fprintf(w, "Top %d search terms:\n", topN)
+	printTable(w, width, referrers.TopNSearchTerms)
+
+	for _, pages := range referrers.LandingPages {
+		fmt.Fprintf(w, "Top %d landing pages from %s:\n", topN, pages.Domain)
+		printTable(w, width, pages.TopNLandingPages)
+	}
+}
+
+// maxParseIssues limits the number of parse errors and warnings listed, to keep output readable for large logs.
+const maxParseIssues = 10
+
+// printParseReport prints a summary of any log lines which were omitted or flagged during parsing, listing
+// them unless quiet.
+func printParseReport(w io.Writer, width int, quiet bool, report *log.ParseReport) {
+	if report == nil || len(report.Errors) == 0 && len(report.Warnings) == 0 {
+		return
+	}
+
+	_ = RenderParseSummary(w, report)
+
+	if !quiet {
+		printParseIssues(w, width, "error", report.Errors)
+		printParseIssues(w, width, "warning", report.Warnings)
+	}
+	fmt.Fprintln(w)
+}
+
+// RenderParseSummary writes a one line summary of any log lines which were omitted or flagged during parsing,
+// for commands which output data rather than a report, e.g. to stderr alongside an export.
+func RenderParseSummary(w io.Writer, report *log.ParseReport) error {
+	if report == nil || len(report.Errors) == 0 && len(report.Warnings) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "Parsed %d of %d log lines, %d omitted, %d warnings\n",
+		report.ParsedLines(), report.TotalLines, len(report.Errors), len(report.Warnings))
+
+	return err
+}
+
+func printParseIssues(w io.Writer, width int, kind string, issues []log.ParseIssue) {
+	for i, issue := range issues {
+		if i == maxParseIssues {
+			fmt.Fprintf(w, "  ... and %d more %ss\n", len(issues)-maxParseIssues, kind)
+			break
 		}
 
+		line := fmt.Sprintf("  %s on line %d: %s", kind, issue.LineNumber, issue.Message)
+		if width > 0 {
+			line = truncate(line, max(width, minColumnWidth))
+		}
+
+		fmt.Fprintln(w, line)
+	}
+}
+
+func printTable(w io.Writer, width int, results [][]string) {
+	rows := [][]string{{results[0][0], results[0][1]}}
+	for _, row := range results[1:] {
 		count, err := log.ParseInt(row[1])
 		if err != nil {
 			// this should never happen
-			fmt.Printf("error parsing count: %v\n", err)
+			fmt.Fprintf(w, "error parsing count: %v\n", err)
 		}
 
-		tbl.AddRow(row[0], count)
+		rows = append(rows, []string{row[0], strconv.Itoa(count)})
+	}
+	truncateColumn(rows, 0, width)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New(rows[0][0], rows[0][1]).WithWriter(w)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range rows[1:] {
+		tbl.AddRow(row[0], row[1])
 	}
 
 	tbl.Print()
-	fmt.Println()
+	fmt.Fprintln(w)
+}
+
+// columnPadding is the space printed after each column of a table.
+const columnPadding = 2
+
+// minColumnWidth is the narrowest a column is truncated to, so that its values remain recognisable in narrow
+// terminals.
+const minColumnWidth = 16
+
+// truncateColumn truncates the values of a column, including its header in the first row, so that the table
+// fits in width columns. A width of 0 leaves the table unchanged.
+func truncateColumn(rows [][]string, col int, width int) {
+	if width <= 0 {
+		return
+	}
+
+	available := width
+	for c := range rows[0] {
+		if c == col {
+			available -= columnPadding
+			continue
+		}
+
+		columnWidth := 0
+		for _, row := range rows {
+			columnWidth = max(columnWidth, displayWidth(row[c]))
+		}
+
+		available -= columnWidth + columnPadding
+	}
+
+	for _, row := range rows {
+		row[col] = truncate(row[col], max(available, minColumnWidth))
+	}
+}
+
+// truncate shortens a string to width runes, marking it with an ellipsis when shortened.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:width-1]) + "…"
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// displayWidth is the width of a string in the terminal, ignoring colour escape sequences, so that tables with
+// coloured values are aligned.
+func displayWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
 }
 
 // printDigioLogo prints the Digio logo with colors.
-func printDigioLogo() {
+func printDigioLogo(w io.Writer) {
 	const digioLogo = `
     
          xxxxxx                                    $$$$$$   $$$$$$                          $$$$$$                      
@@ -87,9 +467,9 @@ xxxxxx      ::::::     ++++++++      $$$$$$       $$$$$$$   $$$$$$  $$$$$$$
 	for _, line := range digioLogo {
 		for _, char := range string(line) {
 			if color, ok := colors[char]; ok {
-				color.Print(string(char))
+				color.Fprint(w, string(char))
 			} else {
-				fmt.Print(string(char))
+				fmt.Fprint(w, string(char))
 			}
 		}
 	}
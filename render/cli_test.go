@@ -0,0 +1,327 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+var update = flag.Bool("update", false, "update the golden files of rendered output")
+
+// assertGolden compares rendered output with a golden file in testdata, or updates the golden file when the
+// tests are run with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, got, 0644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+// disableColor disables colour output for the duration of a test.
+func disableColor(t *testing.T) {
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	color.NoColor = true
+}
+
+// testAllAnalyses returns an analysis with every optional section set, and a parse report with errors and more
+// warnings than are listed.
+func testAllAnalyses() (*log.LogAnalysis, *log.ParseReport) {
+	warnings := make([]log.ParseIssue, 12)
+	for i := range warnings {
+		warnings[i] = log.ParseIssue{LineNumber: i + 3, Message: `IP "50.112.00.11" has leading zeros, normalised to 50.112.0.11`}
+	}
+
+	analysis := &log.LogAnalysis{
+		RequestCount:        6,
+		UniqueIPCount:       3,
+		TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+		TopNMostActiveIPs:   [][]string{{"IPPrefix", "IPPrefix_COUNT"}, {"168.41.191.0/24", "4.000000"}, {"177.71.128.0/24", "2.000000"}},
+		TopNCountries:       [][]string{{"Country", "Country_COUNT"}, {"AU", "4.000000"}, {"BR", "2.000000"}},
+		TopNASNs:            [][]string{{"Network", "Network_COUNT"}, {"AS64500 Example Networks", "4.000000"}, {"AS64501 Example Telecom", "2.000000"}},
+		GroupedBy: []log.ExtraFieldValues{
+			{Field: "vhost", TopNValues: [][]string{{"vhost", "vhost_COUNT"}, {"example.com", "12.000000"}, {"example.net", "4.000000"}}},
+		},
+		Sessions: &log.SessionAnalysis{
+			SessionCount:    3,
+			AverageLength:   2,
+			AverageDuration: 90 * time.Second,
+			TopNEntryPages:  [][]string{{"EntryPage", "EntryPage_COUNT"}, {"/home", "2.000000"}, {"/about", "1.000000"}},
+			TopNExitPages:   [][]string{{"ExitPage", "ExitPage_COUNT"}, {"/about", "2.000000"}, {"/home", "1.000000"}},
+			TopNTransitions: [][]string{{"Transition", "Transition_COUNT"}, {"/home -> /about", "2.000000"}},
+		},
+		Methods: &log.MethodAnalysis{
+			MalformedCount: 1,
+			TopNMethods:    [][]string{{"Method", "Method_COUNT"}, {"GET", "5.000000"}, {"POST", "1.000000"}},
+			TopNProtocols:  [][]string{{"Protocol", "Protocol_COUNT"}, {"HTTP/1.1", "4.000000"}, {"HTTP/2", "2.000000"}},
+			URLsByMethod: []log.MethodURLs{
+				{Method: "GET", TopNURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}}},
+				{Method: "POST", TopNURLs: [][]string{{"URL", "URL_COUNT"}, {"/login", "1.000000"}}},
+			},
+		},
+		Users: &log.UserAnalysis{
+			AuthenticatedCount: 3,
+			AnonymousCount:     3,
+			AuthenticatedShare: 0.5,
+			UserCount:          1,
+			TopNUsers: []log.UserActivity{
+				{
+					User:         "admin",
+					RequestCount: 3,
+					URLCount:     2,
+					FirstSeen:    time.Date(2018, 7, 11, 17, 31, 5, 0, time.UTC),
+					LastSeen:     time.Date(2018, 7, 11, 17, 33, 1, 0, time.UTC),
+					TopNURLs:     [][]string{{"URL", "URL_COUNT"}, {"/hosting/", "2.000000"}, {"/asset.js", "1.000000"}},
+				},
+			},
+		},
+		Referrers: &log.ReferrerAnalysis{
+			DirectCount:       3,
+			InternalCount:     1,
+			ExternalCount:     2,
+			SearchCount:       1,
+			TopNDomains:       [][]string{{"Domain", "Domain_COUNT"}, {"google.com", "1.000000"}, {"news.ycombinator.com", "1.000000"}},
+			TopNSearchEngines: [][]string{{"SearchEngine", "SearchEngine_COUNT"}, {"Google", "1.000000"}},
+			TopNSearchTerms:   [][]string{{"SearchTerm", "SearchTerm_COUNT"}, {"digio careers", "1.000000"}},
+			LandingPages: []log.ReferrerLandingPages{
+				{Domain: "google.com", TopNLandingPages: [][]string{{"LandingPage", "LandingPage_COUNT"}, {"/careers/", "1.000000"}}},
+				{Domain: "news.ycombinator.com", TopNLandingPages: [][]string{{"LandingPage", "LandingPage_COUNT"}, {"/blog/", "1.000000"}}},
+			},
+		},
+		Latency: &log.LatencyAnalysis{
+			RequestCount: 16,
+			Latency:      log.Latency{P50: 12345 * time.Microsecond, P90: 456 * time.Millisecond, P99: 2345 * time.Millisecond},
+			TopNURLs: []log.URLLatency{
+				{URL: "/", RequestCount: 9, Latency: log.Latency{P50: 850 * time.Microsecond, P90: 12 * time.Millisecond, P99: 15 * time.Millisecond}},
+				{URL: "/docs/", RequestCount: 4, Latency: log.Latency{P50: 45678 * time.Microsecond, P90: 456 * time.Millisecond, P99: 456 * time.Millisecond}},
+			},
+			TopNSlowestURLs: []log.URLLatency{
+				{URL: "/report", RequestCount: 1, Latency: log.Latency{P50: 2345 * time.Millisecond, P90: 2345 * time.Millisecond, P99: 2345 * time.Millisecond}},
+				{URL: "/docs/", RequestCount: 4, Latency: log.Latency{P50: 45678 * time.Microsecond, P90: 456 * time.Millisecond, P99: 456 * time.Millisecond}},
+			},
+			OverTime: []log.IntervalLatency{
+				{Start: time.Date(2018, 7, 11, 17, 0, 0, 0, time.UTC), RequestCount: 10, Latency: log.Latency{P50: 2 * time.Millisecond, P90: 40 * time.Millisecond, P99: 456 * time.Millisecond}},
+				{Start: time.Date(2018, 7, 11, 18, 0, 0, 0, time.UTC), RequestCount: 6, Latency: log.Latency{P50: 45 * time.Millisecond, P90: 2345 * time.Millisecond, P99: 2345 * time.Millisecond}},
+			},
+		},
+		Findings: []log.Finding{
+			{Severity: log.SeverityHigh, Kind: "sensitive-path", Subject: "168.41.191.40", Detail: "1 requests for environment file paths, e.g. /.env", Count: 1},
+			{Severity: log.SeverityLow, Kind: "unusual-method", Subject: "PROPFIND", Detail: "1 requests from 1 IPs", Count: 1},
+		},
+	}
+	report := &log.ParseReport{
+		TotalLines: 16,
+		Errors:     []log.ParseIssue{{LineNumber: 2, Message: "invalid log entry"}},
+		Warnings:   warnings,
+	}
+
+	return analysis, report
+}
+
+// testDiff returns a diff with every rank status.
+func testDiff() *log.AnalysisDiff {
+	percentChange := 50.0
+	return &log.AnalysisDiff{
+		Requests:  log.MetricDelta{Baseline: 100, Current: 150, PercentChange: &percentChange, Significant: true},
+		UniqueIPs: log.MetricDelta{Baseline: 10, Current: 10, PercentChange: new(float64)},
+		ErrorRate: log.MetricDelta{Baseline: 0, Current: 0.1, Significant: true},
+		Bandwidth: log.MetricDelta{Baseline: 0, Current: 0},
+		URLs: []log.RankChange{
+			{Value: "/home", BaselineRank: 2, CurrentRank: 1, BaselineCount: 20, CurrentCount: 40, Status: log.RankUp},
+			{Value: "/new", CurrentRank: 2, CurrentCount: 30, Status: log.RankNew},
+			{Value: "/about", BaselineRank: 1, BaselineCount: 30, Status: log.RankGone},
+		},
+		IPs: []log.RankChange{
+			{Value: "192.168.0.1", BaselineRank: 1, CurrentRank: 1, BaselineCount: 10, CurrentCount: 12, Status: log.RankUnchanged},
+			{Value: "192.168.0.2", BaselineRank: 1, CurrentRank: 2, BaselineCount: 9, CurrentCount: 8, Status: log.RankDown},
+		},
+	}
+}
+
+func Test_TableRenderer_RenderAnalysis(t *testing.T) {
+	disableColor(t)
+
+	meta := ReportMetadata{LogFile: "access.log", TopN: 2}
+
+	allAnalysis, allReport := testAllAnalyses()
+
+	tests := []struct {
+		name     string
+		analysis *log.LogAnalysis
+		report   *log.ParseReport
+	}{
+		{
+			name: "analysis",
+			analysis: &log.LogAnalysis{
+				RequestCount:        6,
+				UniqueIPCount:       3,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}, {"192.168.0.2", "2.000000"}},
+			},
+			report: &log.ParseReport{TotalLines: 6},
+		},
+		{
+			name:     "all analyses",
+			analysis: allAnalysis,
+			report:   allReport,
+		},
+		{
+			name: "no response times",
+			analysis: &log.LogAnalysis{
+				RequestCount:        6,
+				UniqueIPCount:       3,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}, {"192.168.0.2", "2.000000"}},
+				Latency:             &log.LatencyAnalysis{},
+			},
+			report: &log.ParseReport{TotalLines: 6},
+		},
+		{
+			name: "no sessions",
+			analysis: &log.LogAnalysis{
+				RequestCount:        6,
+				UniqueIPCount:       3,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}, {"192.168.0.2", "2.000000"}},
+				Sessions: &log.SessionAnalysis{
+					TopNEntryPages:  [][]string{{"EntryPage", "EntryPage_COUNT"}},
+					TopNExitPages:   [][]string{{"ExitPage", "ExitPage_COUNT"}},
+					TopNTransitions: [][]string{{"Transition", "Transition_COUNT"}},
+				},
+			},
+			report: &log.ParseReport{TotalLines: 6},
+		},
+		{
+			name: "approximate analysis",
+			analysis: &log.LogAnalysis{
+				RequestCount:        50000,
+				UniqueIPCount:       2013,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "9120.000000"}, {"/about", "4571.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "31.000000"}, {"192.168.0.2", "29.000000"}},
+				Approximation:       &log.ApproximationBounds{UniqueCountError: 0.008125, CountErrorBound: 50, Confidence: 0.99},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := (&TableRenderer{}).RenderAnalysis(&buf, meta, tt.analysis, tt.report)
+			assert.NoError(t, err)
+
+			assertGolden(t, "table_"+strings.ReplaceAll(tt.name, " ", "_"), buf.Bytes())
+		})
+	}
+}
+
+func Test_TableRenderer_RenderDiff(t *testing.T) {
+	disableColor(t)
+
+	diff := testDiff()
+
+	var buf bytes.Buffer
+	err := (&TableRenderer{}).RenderDiff(&buf, ReportMetadata{LogFile: "new.log", BaselineLogFile: "old.log", TopN: 2}, diff)
+	assert.NoError(t, err)
+
+	assertGolden(t, "table_diff", buf.Bytes())
+}
+
+func Test_TableRenderer_RenderAnalysis_options(t *testing.T) {
+	disableColor(t)
+
+	analysis := &log.LogAnalysis{
+		RequestCount:  3,
+		UniqueIPCount: 2,
+		TopNMostVisitedURLs: [][]string{
+			{"URL", "URL_COUNT"},
+			{"/blog/2018/08/survey-your-opinion-matters/?utm_source=newsletter&utm_medium=email", "2.000000"},
+			{"/home", "1.000000"},
+		},
+		TopNMostActiveIPs: [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "2.000000"}, {"192.168.0.2", "1.000000"}},
+		Findings: []log.Finding{
+			{Severity: log.SeverityMedium, Kind: "bot", Subject: "192.168.0.1", Detail: "2 requests with user agent Mozilla/5.0 (compatible; MJ12bot/v1.4.0; http://mj12bot.com/)", Count: 2},
+		},
+	}
+	report := &log.ParseReport{
+		TotalLines: 4,
+		Errors:     []log.ParseIssue{{LineNumber: 4, Message: `invalid log entry: 50.112.00.11 - admin [11/Jul/2018:17:31:56 +0200] "GET /asset.js HTTP/1.1"`}},
+	}
+
+	tests := []struct {
+		name    string
+		options TableOptions
+	}{
+		{
+			name:    "narrow",
+			options: TableOptions{NoBanner: true, Width: 60},
+		},
+		{
+			name:    "quiet",
+			options: TableOptions{Quiet: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := (&TableRenderer{TableOptions: tt.options}).RenderAnalysis(&buf, ReportMetadata{LogFile: "access.log", TopN: 2}, analysis, report)
+			assert.NoError(t, err)
+
+			assertGolden(t, "table_"+tt.name, buf.Bytes())
+		})
+	}
+}
+
+func Test_truncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		width int
+		want  string
+	}{
+		{name: "shorter", value: "/home", width: 10, want: "/home"},
+		{name: "equal", value: "/home", width: 5, want: "/home"},
+		{name: "longer", value: "/home/about", width: 6, want: "/home…"},
+		{name: "multibyte", value: "/café/menu", width: 6, want: "/café…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, truncate(tt.value, tt.width))
+		})
+	}
+}
+
+// failingWriter fails every write, as a closed pipe would.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func Test_TableRenderer_RenderAnalysis_writeError(t *testing.T) {
+	analysis := &log.LogAnalysis{
+		TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}},
+		TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}},
+	}
+
+	err := (&TableRenderer{}).RenderAnalysis(failingWriter{}, ReportMetadata{}, analysis, nil)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// RenderDiff writes the differences between a baseline and current analysis as tables, highlighting
+// significant movements.
+func (r *TableRenderer) RenderDiff(w io.Writer, meta ReportMetadata, diff *log.AnalysisDiff) error {
+	ew := &errWriter{w: w}
+
+	fmt.Fprintf(ew, "Comparison of Log File: %s to baseline: %s\n\n", meta.LogFile, meta.BaselineLogFile)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New("Metric", "Baseline", "Current", "Change").WithWriter(ew).WithWidthFunc(displayWidth)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	tbl.AddRow("Requests", fmt.Sprintf("%.0f", diff.Requests.Baseline), fmt.Sprintf("%.0f", diff.Requests.Current), formatDelta(diff.Requests))
+	tbl.AddRow("Unique IPs", fmt.Sprintf("%.0f", diff.UniqueIPs.Baseline), fmt.Sprintf("%.0f", diff.UniqueIPs.Current), formatDelta(diff.UniqueIPs))
+	tbl.AddRow("Error rate", fmt.Sprintf("%.1f%%", diff.ErrorRate.Baseline*100), fmt.Sprintf("%.1f%%", diff.ErrorRate.Current*100), formatDelta(diff.ErrorRate))
+	tbl.AddRow("Bandwidth", fmt.Sprintf("%.0f B", diff.Bandwidth.Baseline), fmt.Sprintf("%.0f B", diff.Bandwidth.Current), formatDelta(diff.Bandwidth))
+	tbl.Print()
+	fmt.Fprintln(ew)
+
+	fmt.Fprintf(ew, "Top %d most visited URLs:\n", meta.TopN)
+	printRankChanges(ew, r.Width, "URL", diff.URLs)
+
+	fmt.Fprintf(ew, "Top %d most active IPs:\n", meta.TopN)
+	printRankChanges(ew, r.Width, "IP", diff.IPs)
+
+	return ew.err
+}
+
+// formatDelta formats the percentage change of a metric, highlighting significant changes.
+func formatDelta(delta log.MetricDelta) string {
+	var change string
+	switch {
+	case delta.PercentChange == nil && delta.Current == 0:
+		change = "-"
+	case delta.PercentChange == nil:
+		change = "new"
+	default:
+		change = fmt.Sprintf("%+.1f%%", *delta.PercentChange)
+	}
+
+	if delta.Significant {
+		return color.New(color.FgHiYellow, color.Bold).Sprint(change + " !")
+	}
+
+	return change
+}
+
+func printRankChanges(w io.Writer, width int, valueName string, changes []log.RankChange) {
+	rows := [][]string{{valueName, "Baseline", "Current", "Movement"}}
+	for _, change := range changes {
+		rows = append(rows, []string{change.Value, formatRank(change.BaselineRank, change.BaselineCount), formatRank(change.CurrentRank, change.CurrentCount), formatMovement(change)})
+	}
+	truncateColumn(rows, 0, width)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New(rows[0][0], rows[0][1], rows[0][2], rows[0][3]).WithWriter(w).WithWidthFunc(displayWidth)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range rows[1:] {
+		tbl.AddRow(row[0], row[1], row[2], row[3])
+	}
+
+	tbl.Print()
+	fmt.Fprintln(w)
+}
+
+// formatRank formats a rank and count, e.g. "#1 (42)", or "-" if the value was not ranked.
+func formatRank(rank int, count int) string {
+	if rank == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("#%d (%d)", rank, count)
+}
+
+func formatMovement(change log.RankChange) string {
+	switch change.Status {
+	case log.RankNew:
+		return color.New(color.FgHiGreen, color.Bold).Sprint("new")
+	case log.RankGone:
+		return color.New(color.FgHiRed, color.Bold).Sprint("gone")
+	case log.RankUp:
+		return color.New(color.FgHiGreen).Sprintf("up %d", change.BaselineRank-change.CurrentRank)
+	case log.RankDown:
+		return color.New(color.FgHiRed).Sprintf("down %d", change.CurrentRank-change.BaselineRank)
+	default:
+		return "unchanged"
+	}
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+//go:embed templates/report.html.tmpl
+var templates embed.FS
+
+var reportTemplate = template.Must(template.New("report.html.tmpl").Funcs(template.FuncMap{
+	"chart":      newBarChart,
+	"rows":       newTableRows,
+	"percent":    func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"duration":   func(d time.Duration) string { return d.Round(time.Second).String() },
+	"latency":    formatLatency,
+	"limit":      limitParseIssues,
+	"remaining":  func(issues []log.ParseIssue) int { return max(len(issues)-maxParseIssues, 0) },
+	"formatTime": func(t time.Time) string { return t.Format(time.RFC1123) },
+}).ParseFS(templates, "templates/report.html.tmpl"))
+
+// HTMLRenderer renders reports as self-contained HTML pages, with embedded CSS and SVG charts, which can be
+// saved and shared as a single file.
+type HTMLRenderer struct{}
+
+// htmlReport is the data of the HTML report template.
+type htmlReport struct {
+	ReportMetadata
+	Analysis    *log.LogAnalysis
+	ParseReport *log.ParseReport
+	// FindingsEnabled shows the anomalies section when anomaly detection is enabled, even with no findings
+	FindingsEnabled bool
+}
+
+// tableRow is a row of a top N table, with the count parsed from the analysis records.
+type tableRow struct {
+	Value string
+	Count int
+}
+
+// barChart is a horizontal bar chart of a top N table, drawn as inline SVG.
+type barChart struct {
+	Height int
+	Bars   []bar
+}
+
+type bar struct {
+	Label string
+	Count int
+	Y     int
+	Width float64
+}
+
+// Dimensions of bar charts, in pixels.
+const (
+	chartBarHeight = 24
+	chartBarWidth  = 480
+)
+
+// RenderAnalysis writes the analysis results, parse report and report metadata as an HTML page.
+func (r *HTMLRenderer) RenderAnalysis(w io.Writer, meta ReportMetadata, logAnalysis *log.LogAnalysis, report *log.ParseReport) error {
+	err := reportTemplate.Execute(w, htmlReport{
+		ReportMetadata:  meta,
+		Analysis:        logAnalysis,
+		ParseReport:     report,
+		FindingsEnabled: logAnalysis.Findings != nil,
+	})
+	if err != nil {
+		return fmt.Errorf("error rendering html report: %w", err)
+	}
+
+	return nil
+}
+
+func newTableRows(records [][]string) []tableRow {
+	rows := make([]tableRow, 0, len(records))
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+
+		count, _ := log.ParseInt(record[1])
+		rows = append(rows, tableRow{Value: record[0], Count: count})
+	}
+
+	return rows
+}
+
+// newBarChart scales the bars of a top N table relative to the highest count.
+func newBarChart(records [][]string) barChart {
+	rows := newTableRows(records)
+
+	maxCount := 0
+	for _, row := range rows {
+		maxCount = max(maxCount, row.Count)
+	}
+
+	chart := barChart{Height: len(rows) * chartBarHeight}
+	for i, row := range rows {
+		width := 0.0
+		if maxCount > 0 {
+			width = float64(row.Count) / float64(maxCount) * chartBarWidth
+		}
+
+		chart.Bars = append(chart.Bars, bar{Label: row.Value, Count: row.Count, Y: i * chartBarHeight, Width: width})
+	}
+
+	return chart
+}
+
+func limitParseIssues(issues []log.ParseIssue) []log.ParseIssue {
+	return issues[:min(len(issues), maxParseIssues)]
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func Test_HTMLRenderer_RenderAnalysis(t *testing.T) {
+	meta := ReportMetadata{
+		LogFile:     "access.log",
+		TopN:        2,
+		Filter:      "status >= 400",
+		Analyses:    []string{"sessions", "methods", "users", "referrers", "latency", "anomalies"},
+		GeneratedAt: time.Date(2018, 7, 12, 9, 0, 0, 0, time.UTC),
+	}
+	allAnalysis, allReport := testAllAnalyses()
+
+	tests := []struct {
+		name     string
+		analysis *log.LogAnalysis
+		report   *log.ParseReport
+	}{
+		{
+			name: "analysis",
+			analysis: &log.LogAnalysis{
+				RequestCount:        6,
+				UniqueIPCount:       3,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "3.000000"}, {"192.168.0.2", "2.000000"}},
+			},
+			report: &log.ParseReport{TotalLines: 6},
+		},
+		{
+			name:     "all analyses",
+			analysis: allAnalysis,
+			report:   allReport,
+		},
+		{
+			name: "approximate analysis",
+			analysis: &log.LogAnalysis{
+				RequestCount:        50000,
+				UniqueIPCount:       2013,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "9120.000000"}, {"/about", "4571.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}, {"192.168.0.1", "31.000000"}, {"192.168.0.2", "29.000000"}},
+				Approximation:       &log.ApproximationBounds{UniqueCountError: 0.008125, CountErrorBound: 50, Confidence: 0.99},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := (&HTMLRenderer{}).RenderAnalysis(&buf, meta, tt.analysis, tt.report)
+			assert.NoError(t, err)
+
+			assertGolden(t, "html_"+strings.ReplaceAll(tt.name, " ", "_"), buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// JSONRenderer renders reports as indented JSON for other tools.
+type JSONRenderer struct{}
+
+// jsonReport is the JSON representation of the analysis results.
+type jsonReport struct {
+	LogFile     string          `json:"logFile"`
+	TopN        int             `json:"topN"`
+	Analysis    JSONAnalysis    `json:"analysis"`
+	ParseReport jsonParseReport `json:"parseReport"`
+}
+
+// JSONAnalysis is the JSON representation of a LogAnalysis, with camelCase keys and top N tables as lists of
+// values and integer counts. Optional analyses are omitted when not enabled.
+type JSONAnalysis struct {
+	RequestCount  int              `json:"requestCount"`
+	ErrorRate     float64          `json:"errorRate"`
+	TotalBytes    int              `json:"totalBytes"`
+	UniqueIPCount int              `json:"uniqueIpCount"`
+	TopURLs       []jsonCount      `json:"topUrls"`
+	TopIPs        []jsonCount      `json:"topIps"`
+	TopCountries  []jsonCount      `json:"topCountries,omitempty"`
+	TopNetworks   []jsonCount      `json:"topNetworks,omitempty"`
+	GroupedBy     []jsonGroup      `json:"groupedBy,omitempty"`
+	Sessions      *jsonSessions    `json:"sessions,omitempty"`
+	Methods       *jsonMethods     `json:"methods,omitempty"`
+	Users         *jsonUsers       `json:"users,omitempty"`
+	Referrers     *jsonReferrers   `json:"referrers,omitempty"`
+	Latency       *jsonLatency     `json:"latency,omitempty"`
+	Anomalies     *jsonAnomalies   `json:"anomalies,omitempty"`
+	Approximation *jsonApproximate `json:"approximation,omitempty"`
+}
+
+// jsonCount is a value of a top N table and the number of times it occurred.
+type jsonCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type jsonGroup struct {
+	Field string      `json:"field"`
+	Top   []jsonCount `json:"top"`
+}
+
+type jsonSessions struct {
+	SessionCount           int         `json:"sessionCount"`
+	AverageLength          float64     `json:"averageLength"`
+	AverageDurationSeconds float64     `json:"averageDurationSeconds"`
+	TopEntryPages          []jsonCount `json:"topEntryPages"`
+	TopExitPages           []jsonCount `json:"topExitPages"`
+	TopTransitions         []jsonCount `json:"topTransitions"`
+}
+
+type jsonMethods struct {
+	MalformedCount int              `json:"malformedCount"`
+	TopMethods     []jsonCount      `json:"topMethods"`
+	TopProtocols   []jsonCount      `json:"topProtocols"`
+	URLsByMethod   []jsonMethodURLs `json:"urlsByMethod"`
+}
+
+type jsonMethodURLs struct {
+	Method  string      `json:"method"`
+	TopURLs []jsonCount `json:"topUrls"`
+}
+
+type jsonUsers struct {
+	AuthenticatedCount int            `json:"authenticatedCount"`
+	AnonymousCount     int            `json:"anonymousCount"`
+	AuthenticatedShare float64        `json:"authenticatedShare"`
+	UserCount          int            `json:"userCount"`
+	TopUsers           []jsonActivity `json:"topUsers"`
+}
+
+type jsonActivity struct {
+	User         string `json:"user"`
+	RequestCount int    `json:"requestCount"`
+	URLCount     int    `json:"urlCount"`
+	// FirstSeen and LastSeen are omitted when the times of the user's requests could not be parsed
+	FirstSeen *time.Time  `json:"firstSeen,omitempty"`
+	LastSeen  *time.Time  `json:"lastSeen,omitempty"`
+	TopURLs   []jsonCount `json:"topUrls"`
+}
+
+type jsonReferrers struct {
+	DirectCount      int                `json:"directCount"`
+	InternalCount    int                `json:"internalCount"`
+	ExternalCount    int                `json:"externalCount"`
+	SearchCount      int                `json:"searchCount"`
+	TopDomains       []jsonCount        `json:"topDomains"`
+	TopSearchEngines []jsonCount        `json:"topSearchEngines"`
+	TopSearchTerms   []jsonCount        `json:"topSearchTerms"`
+	LandingPages     []jsonLandingPages `json:"landingPages"`
+}
+
+type jsonLandingPages struct {
+	Domain          string      `json:"domain"`
+	TopLandingPages []jsonCount `json:"topLandingPages"`
+}
+
+// jsonPercentiles are response time percentiles in milliseconds.
+type jsonPercentiles struct {
+	P50 float64 `json:"p50Ms"`
+	P90 float64 `json:"p90Ms"`
+	P99 float64 `json:"p99Ms"`
+}
+
+type jsonLatency struct {
+	RequestCount int `json:"requestCount"`
+	jsonPercentiles
+	TopURLs     []jsonURLLatency      `json:"topUrls"`
+	SlowestURLs []jsonURLLatency      `json:"slowestUrls"`
+	OverTime    []jsonIntervalLatency `json:"overTime"`
+}
+
+type jsonURLLatency struct {
+	URL          string `json:"url"`
+	RequestCount int    `json:"requestCount"`
+	jsonPercentiles
+}
+
+type jsonIntervalLatency struct {
+	Start        time.Time `json:"start"`
+	RequestCount int       `json:"requestCount"`
+	jsonPercentiles
+}
+
+type jsonAnomalies struct {
+	Findings []jsonFinding `json:"findings"`
+}
+
+type jsonFinding struct {
+	Severity string `json:"severity"`
+	Kind     string `json:"kind"`
+	Subject  string `json:"subject"`
+	Detail   string `json:"detail"`
+	Count    int    `json:"count"`
+}
+
+type jsonApproximate struct {
+	UniqueCountError float64 `json:"uniqueCountError"`
+	CountErrorBound  int     `json:"countErrorBound"`
+	Confidence       float64 `json:"confidence"`
+}
+
+type jsonParseReport struct {
+	TotalLines  int              `json:"totalLines"`
+	ParsedLines int              `json:"parsedLines"`
+	Errors      []jsonParseIssue `json:"errors"`
+	Warnings    []jsonParseIssue `json:"warnings"`
+}
+
+type jsonParseIssue struct {
+	LineNumber int    `json:"lineNumber"`
+	Message    string `json:"message"`
+}
+
+// RenderAnalysis writes the analysis results and parse report as indented JSON.
+func (r *JSONRenderer) RenderAnalysis(w io.Writer, meta ReportMetadata, logAnalysis *log.LogAnalysis, report *log.ParseReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(jsonReport{
+		LogFile:     meta.LogFile,
+		TopN:        meta.TopN,
+		Analysis:    NewJSONAnalysis(logAnalysis),
+		ParseReport: newJSONParseReport(report),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding analysis results: %w", err)
+	}
+
+	return nil
+}
+
+// NewJSONAnalysis returns the JSON representation of a LogAnalysis.
+func NewJSONAnalysis(la *log.LogAnalysis) JSONAnalysis {
+	analysis := JSONAnalysis{
+		RequestCount:  la.RequestCount,
+		ErrorRate:     la.ErrorRate,
+		TotalBytes:    la.TotalBytes,
+		UniqueIPCount: la.UniqueIPCount,
+		TopURLs:       newJSONCounts(la.TopNMostVisitedURLs),
+		TopIPs:        newJSONCounts(la.TopNMostActiveIPs),
+	}
+
+	// countries and networks are only set by GeoIP analysis
+	if la.TopNCountries != nil {
+		analysis.TopCountries = newJSONCounts(la.TopNCountries)
+	}
+	if la.TopNASNs != nil {
+		analysis.TopNetworks = newJSONCounts(la.TopNASNs)
+	}
+
+	for _, group := range la.GroupedBy {
+		analysis.GroupedBy = append(analysis.GroupedBy, jsonGroup{Field: group.Field, Top: newJSONCounts(group.TopNValues)})
+	}
+
+	if s := la.Sessions; s != nil {
+		analysis.Sessions = &jsonSessions{
+			SessionCount:           s.SessionCount,
+			AverageLength:          s.AverageLength,
+			AverageDurationSeconds: s.AverageDuration.Seconds(),
+			TopEntryPages:          newJSONCounts(s.TopNEntryPages),
+			TopExitPages:           newJSONCounts(s.TopNExitPages),
+			TopTransitions:         newJSONCounts(s.TopNTransitions),
+		}
+	}
+
+	if m := la.Methods; m != nil {
+		analysis.Methods = &jsonMethods{
+			MalformedCount: m.MalformedCount,
+			TopMethods:     newJSONCounts(m.TopNMethods),
+			TopProtocols:   newJSONCounts(m.TopNProtocols),
+			URLsByMethod:   make([]jsonMethodURLs, len(m.URLsByMethod)),
+		}
+		for i, urls := range m.URLsByMethod {
+			analysis.Methods.URLsByMethod[i] = jsonMethodURLs{Method: urls.Method, TopURLs: newJSONCounts(urls.TopNURLs)}
+		}
+	}
+
+	if u := la.Users; u != nil {
+		analysis.Users = &jsonUsers{
+			AuthenticatedCount: u.AuthenticatedCount,
+			AnonymousCount:     u.AnonymousCount,
+			AuthenticatedShare: u.AuthenticatedShare,
+			UserCount:          u.UserCount,
+			TopUsers:           make([]jsonActivity, len(u.TopNUsers)),
+		}
+		for i, user := range u.TopNUsers {
+			analysis.Users.TopUsers[i] = jsonActivity{
+				User:         user.User,
+				RequestCount: user.RequestCount,
+				URLCount:     user.URLCount,
+				FirstSeen:    timeOrNil(user.FirstSeen),
+				LastSeen:     timeOrNil(user.LastSeen),
+				TopURLs:      newJSONCounts(user.TopNURLs),
+			}
+		}
+	}
+
+	if r := la.Referrers; r != nil {
+		analysis.Referrers = &jsonReferrers{
+			DirectCount:      r.DirectCount,
+			InternalCount:    r.InternalCount,
+			ExternalCount:    r.ExternalCount,
+			SearchCount:      r.SearchCount,
+			TopDomains:       newJSONCounts(r.TopNDomains),
+			TopSearchEngines: newJSONCounts(r.TopNSearchEngines),
+			TopSearchTerms:   newJSONCounts(r.TopNSearchTerms),
+			LandingPages:     make([]jsonLandingPages, len(r.LandingPages)),
+		}
+		for i, pages := range r.LandingPages {
+			analysis.Referrers.LandingPages[i] = jsonLandingPages{Domain: pages.Domain, TopLandingPages: newJSONCounts(pages.TopNLandingPages)}
+		}
+	}
+
+	if l := la.Latency; l != nil {
+		analysis.Latency = &jsonLatency{
+			RequestCount:    l.RequestCount,
+			jsonPercentiles: newJSONPercentiles(l.Latency),
+			TopURLs:         newJSONURLLatencies(l.TopNURLs),
+			SlowestURLs:     newJSONURLLatencies(l.TopNSlowestURLs),
+			OverTime:        make([]jsonIntervalLatency, len(l.OverTime)),
+		}
+		for i, interval := range l.OverTime {
+			analysis.Latency.OverTime[i] = jsonIntervalLatency{
+				Start:           interval.Start,
+				RequestCount:    interval.RequestCount,
+				jsonPercentiles: newJSONPercentiles(interval.Latency),
+			}
+		}
+	}
+
+	// findings are only set when anomaly detection is enabled, and an empty list means nothing was found
+	if la.Findings != nil {
+		analysis.Anomalies = &jsonAnomalies{Findings: make([]jsonFinding, len(la.Findings))}
+		for i, finding := range la.Findings {
+			analysis.Anomalies.Findings[i] = jsonFinding{
+				Severity: finding.Severity.String(),
+				Kind:     finding.Kind,
+				Subject:  finding.Subject,
+				Detail:   finding.Detail,
+				Count:    finding.Count,
+			}
+		}
+	}
+
+	if a := la.Approximation; a != nil {
+		analysis.Approximation = &jsonApproximate{
+			UniqueCountError: a.UniqueCountError,
+			CountErrorBound:  a.CountErrorBound,
+			Confidence:       a.Confidence,
+		}
+	}
+
+	return analysis
+}
+
+// newJSONCounts converts the records of a top N table, with a header row and float counts, to values and
+// integer counts.
+func newJSONCounts(records [][]string) []jsonCount {
+	rows := newTableRows(records)
+
+	counts := make([]jsonCount, len(rows))
+	for i, row := range rows {
+		counts[i] = jsonCount{Value: row.Value, Count: row.Count}
+	}
+
+	return counts
+}
+
+func newJSONPercentiles(latency log.Latency) jsonPercentiles {
+	return jsonPercentiles{P50: millis(latency.P50), P90: millis(latency.P90), P99: millis(latency.P99)}
+}
+
+func newJSONURLLatencies(urls []log.URLLatency) []jsonURLLatency {
+	latencies := make([]jsonURLLatency, len(urls))
+	for i, url := range urls {
+		latencies[i] = jsonURLLatency{URL: url.URL, RequestCount: url.RequestCount, jsonPercentiles: newJSONPercentiles(url.Latency)}
+	}
+
+	return latencies
+}
+
+// millis returns a duration in milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// timeOrNil returns nil for the zero time, so that it is omitted.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+func newJSONParseReport(report *log.ParseReport) jsonParseReport {
+	if report == nil {
+		return jsonParseReport{Errors: []jsonParseIssue{}, Warnings: []jsonParseIssue{}}
+	}
+
+	return jsonParseReport{
+		TotalLines:  report.TotalLines,
+		ParsedLines: report.ParsedLines(),
+		Errors:      newJSONParseIssues(report.Errors),
+		Warnings:    newJSONParseIssues(report.Warnings),
+	}
+}
+
+func newJSONParseIssues(issues []log.ParseIssue) []jsonParseIssue {
+	jsonIssues := make([]jsonParseIssue, len(issues))
+	for i, issue := range issues {
+		jsonIssues[i] = jsonParseIssue{LineNumber: issue.LineNumber, Message: issue.Message}
+	}
+
+	return jsonIssues
+}
+
+// jsonDiffReport is the JSON representation of a comparison between two log periods.
+type jsonDiffReport struct {
+	Baseline string   `json:"baseline"`
+	Current  string   `json:"current"`
+	TopN     int      `json:"topN"`
+	Diff     jsonDiff `json:"diff"`
+}
+
+type jsonDiff struct {
+	Requests  jsonMetricDelta  `json:"requests"`
+	UniqueIPs jsonMetricDelta  `json:"uniqueIps"`
+	ErrorRate jsonMetricDelta  `json:"errorRate"`
+	Bandwidth jsonMetricDelta  `json:"bandwidth"`
+	URLs      []jsonRankChange `json:"urls"`
+	IPs       []jsonRankChange `json:"ips"`
+}
+
+type jsonMetricDelta struct {
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	// PercentChange is null when the baseline is zero
+	PercentChange *float64 `json:"percentChange"`
+	Significant   bool     `json:"significant"`
+}
+
+// jsonRankChange is the movement of a value in a top N list. A rank of 0 means the value was not in the top N
+// for that period.
+type jsonRankChange struct {
+	Value         string `json:"value"`
+	BaselineRank  int    `json:"baselineRank"`
+	CurrentRank   int    `json:"currentRank"`
+	BaselineCount int    `json:"baselineCount"`
+	CurrentCount  int    `json:"currentCount"`
+	Status        string `json:"status"`
+}
+
+// RenderDiff writes the differences between a baseline and current analysis as indented JSON.
+func (r *JSONRenderer) RenderDiff(w io.Writer, meta ReportMetadata, diff *log.AnalysisDiff) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(jsonDiffReport{
+		Baseline: meta.BaselineLogFile,
+		Current:  meta.LogFile,
+		TopN:     meta.TopN,
+		Diff: jsonDiff{
+			Requests:  jsonMetricDelta(diff.Requests),
+			UniqueIPs: jsonMetricDelta(diff.UniqueIPs),
+			ErrorRate: jsonMetricDelta(diff.ErrorRate),
+			Bandwidth: jsonMetricDelta(diff.Bandwidth),
+			URLs:      newJSONRankChanges(diff.URLs),
+			IPs:       newJSONRankChanges(diff.IPs),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding analysis diff: %w", err)
+	}
+
+	return nil
+}
+
+func newJSONRankChanges(changes []log.RankChange) []jsonRankChange {
+	jsonChanges := make([]jsonRankChange, len(changes))
+	for i, change := range changes {
+		jsonChanges[i] = jsonRankChange{
+			Value:         change.Value,
+			BaselineRank:  change.BaselineRank,
+			CurrentRank:   change.CurrentRank,
+			BaselineCount: change.BaselineCount,
+			CurrentCount:  change.CurrentCount,
+			Status:        string(change.Status),
+		}
+	}
+
+	return jsonChanges
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func Test_JSONRenderer_RenderAnalysis(t *testing.T) {
+	meta := ReportMetadata{LogFile: "access.log", TopN: 2}
+	allAnalysis, allReport := testAllAnalyses()
+
+	tests := []struct {
+		name     string
+		analysis *log.LogAnalysis
+		report   *log.ParseReport
+	}{
+		{
+			name: "analysis",
+			analysis: &log.LogAnalysis{
+				RequestCount:        6,
+				UniqueIPCount:       3,
+				TopNMostVisitedURLs: [][]string{{"URL", "URL_COUNT"}, {"/home", "3.000000"}, {"/about", "2.000000"}},
+				TopNMostActiveIPs:   [][]string{{"IP", "IP_COUNT"}},
+				Findings:            []log.Finding{},
+			},
+			report: &log.ParseReport{TotalLines: 6},
+		},
+		{
+			name:     "all analyses",
+			analysis: allAnalysis,
+			report:   allReport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := (&JSONRenderer{}).RenderAnalysis(&buf, meta, tt.analysis, tt.report)
+			assert.NoError(t, err)
+
+			assertGolden(t, "json_"+strings.ReplaceAll(tt.name, " ", "_"), buf.Bytes())
+		})
+	}
+}
+
+func Test_JSONRenderer_RenderDiff(t *testing.T) {
+	var buf bytes.Buffer
+	err := (&JSONRenderer{}).RenderDiff(&buf, ReportMetadata{LogFile: "new.log", BaselineLogFile: "old.log", TopN: 2}, testDiff())
+	assert.NoError(t, err)
+
+	assertGolden(t, "json_diff", buf.Bytes())
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	"github.com/ryannortham/digio-task/export"
+)
+
+// QueryRenderer renders the result of a SQL query over log entries to a writer.
+type QueryRenderer interface {
+	RenderQuery(w io.Writer, meta ReportMetadata, result *export.QueryResult) error
+}
+
+// NewQueryRenderer returns the query renderer of an output format: table, json or csv. The table options only
+// apply to table output.
+func NewQueryRenderer(output string, options TableOptions) (QueryRenderer, error) {
+	switch output {
+	case "", "table":
+		return &TableRenderer{TableOptions: options}, nil
+	case "json":
+		return &JSONRenderer{}, nil
+	case "csv":
+		return &CSVRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format for query: %s", output)
+	}
+}
+
+// RenderQuery writes a table of the query results, with the widest column truncated to fit the terminal.
+func (r *TableRenderer) RenderQuery(w io.Writer, meta ReportMetadata, result *export.QueryResult) error {
+	ew := &errWriter{w: w}
+
+	fmt.Fprintf(ew, "Query results of Log File: %s: %d rows\n\n", meta.LogFile, len(result.Rows))
+	if len(result.Columns) == 0 || len(result.Rows) == 0 {
+		return ew.err
+	}
+
+	rows := [][]string{result.Columns}
+	for _, values := range result.Rows {
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = export.FormatValue(value)
+		}
+		rows = append(rows, row)
+	}
+	truncateColumn(rows, widestColumn(rows), r.Width)
+
+	headers := make([]any, len(result.Columns))
+	for i, column := range rows[0] {
+		headers[i] = column
+	}
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New(headers...).WithWriter(ew)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range rows[1:] {
+		values := make([]any, len(row))
+		for i, value := range row {
+			values[i] = value
+		}
+		tbl.AddRow(values...)
+	}
+
+	tbl.Print()
+	fmt.Fprintln(ew)
+
+	return ew.err
+}
+
+// widestColumn returns the index of the column with the widest value.
+func widestColumn(rows [][]string) int {
+	widest, widestWidth := 0, 0
+	for _, row := range rows {
+		for c, value := range row {
+			if width := displayWidth(value); width > widestWidth {
+				widest, widestWidth = c, width
+			}
+		}
+	}
+
+	return widest
+}
+
+// jsonQueryReport is the JSON representation of the result of a query.
+type jsonQueryReport struct {
+	LogFile string   `json:"logFile"`
+	Query   string   `json:"query"`
+	Columns []string `json:"columns"`
+	// Rows are arrays of values in column order, as column names may repeat
+	Rows [][]any `json:"rows"`
+}
+
+// RenderQuery writes the query results as indented JSON, with NULL as null.
+func (r *JSONRenderer) RenderQuery(w io.Writer, meta ReportMetadata, result *export.QueryResult) error {
+	report := jsonQueryReport{LogFile: meta.LogFile, Query: meta.Query, Columns: result.Columns, Rows: result.Rows}
+	if report.Columns == nil {
+		report.Columns = []string{}
+	}
+	if report.Rows == nil {
+		report.Rows = [][]any{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("error encoding query results: %w", err)
+	}
+
+	return nil
+}
+
+// RenderQuery writes the query results as CSV, with a header row of column names and NULL as an empty value.
+func (r *CSVRenderer) RenderQuery(w io.Writer, meta ReportMetadata, result *export.QueryResult) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(result.Columns); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	record := make([]string, len(result.Columns))
+	for _, values := range result.Rows {
+		for i, value := range values {
+			record[i] = ""
+			if value != nil {
+				record[i] = export.FormatValue(value)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/export"
+)
+
+func Test_QueryRenderer_RenderQuery(t *testing.T) {
+	disableColor(t)
+
+	result := &export.QueryResult{
+		Columns: []string{"URL", "UserAgent", "Requests", "ResponseTime"},
+		Rows: [][]any{
+			{"/docs/manage-websites/", "Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/536.6 (KHTML, like Gecko) Chrome/20.0.1092.0 Safari/536.6", int64(2), 12.5},
+			{"/hosting/", `curl/7.61.0 "quoted"`, int64(1), nil},
+		},
+	}
+
+	meta := ReportMetadata{LogFile: "access.log", Query: "SELECT URL, UserAgent, count(*) AS Requests, avg(ResponseTime) AS ResponseTime FROM logs GROUP BY URL"}
+
+	tests := []struct {
+		name     string
+		renderer QueryRenderer
+	}{
+		{name: "table", renderer: &TableRenderer{TableOptions: TableOptions{Width: 80}}},
+		{name: "json", renderer: &JSONRenderer{}},
+		{name: "csv", renderer: &CSVRenderer{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tt.renderer.RenderQuery(&buf, meta, result)
+			assert.NoError(t, err)
+
+			assertGolden(t, "query_"+tt.name, buf.Bytes())
+		})
+	}
+}
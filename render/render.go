@@ -0,0 +1,80 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// ReportMetadata describes the input and options of a report.
+type ReportMetadata struct {
+	LogFile string
+	// BaselineLogFile is the log file compared against, and is only set for diffs
+	BaselineLogFile string
+	// User is the user whose requests are listed, and is only set for audits
+	User string
+	// Query is the SQL query of query results, and is only set for queries
+	Query       string
+	TopN        int
+	Filter      string
+	Analyses    []string
+	GeneratedAt time.Time
+}
+
+// Renderer renders the analysis of a log, and the report of its parsing, to a writer.
+type Renderer interface {
+	RenderAnalysis(w io.Writer, meta ReportMetadata, logAnalysis *log.LogAnalysis, report *log.ParseReport) error
+}
+
+// DiffRenderer renders the differences between a baseline and current analysis to a writer.
+type DiffRenderer interface {
+	RenderDiff(w io.Writer, meta ReportMetadata, diff *log.AnalysisDiff) error
+}
+
+// NewRenderer returns the renderer of an output format: table, json or html. The table options only apply
+// to table output.
+func NewRenderer(output string, options TableOptions) (Renderer, error) {
+	switch output {
+	case "", "table":
+		return &TableRenderer{TableOptions: options}, nil
+	case "json":
+		return &JSONRenderer{}, nil
+	case "html":
+		return &HTMLRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", output)
+	}
+}
+
+// NewDiffRenderer returns the diff renderer of an output format: table or json. The table options only apply
+// to table output.
+func NewDiffRenderer(output string, options TableOptions) (DiffRenderer, error) {
+	switch output {
+	case "", "table":
+		return &TableRenderer{TableOptions: options}, nil
+	case "json":
+		return &JSONRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format for diff: %s", output)
+	}
+}
+
+// errWriter keeps the first error writing to a writer, so that a report can be written without checking
+// every write.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+
+	n, err := ew.w.Write(p)
+	ew.err = err
+
+	return n, err
+}
@@ -0,0 +1,166 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// RequestsRenderer renders a list of requests, such as the audit trail of a user, to a writer.
+type RequestsRenderer interface {
+	RenderRequests(w io.Writer, meta ReportMetadata, logEntries []log.LogEntry) error
+}
+
+// NewRequestsRenderer returns the requests renderer of an output format: table, json or csv. The table options
+// only apply to table output.
+func NewRequestsRenderer(output string, options TableOptions) (RequestsRenderer, error) {
+	switch output {
+	case "", "table":
+		return &TableRenderer{TableOptions: options}, nil
+	case "json":
+		return &JSONRenderer{}, nil
+	case "csv":
+		return &CSVRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format for requests: %s", output)
+	}
+}
+
+// requestFields are the columns of a request in CSV exports.
+var requestFields = []string{"Time", "IP", "User", "Method", "URL", "Protocol", "StatusCode", "Size", "Referrer", "UserAgent"}
+
+// requestRecord returns the columns of a request in CSV exports. Times are formatted as RFC 3339, or as
+// logged if they could not be parsed.
+func requestRecord(entry log.LogEntry) []string {
+	return []string{
+		requestTime(entry),
+		entry.IP,
+		log.User(entry),
+		entry.Method,
+		entry.URL,
+		entry.Protocol,
+		strconv.Itoa(entry.StatusCode),
+		strconv.Itoa(entry.Size),
+		entry.Referrer,
+		entry.UserAgent,
+	}
+}
+
+func requestTime(entry log.LogEntry) string {
+	if entry.Timestamp.IsZero() {
+		return entry.Time
+	}
+
+	return entry.Timestamp.Format(time.RFC3339)
+}
+
+// RenderRequests writes a table of requests, with long user agents truncated to fit the terminal.
+func (r *TableRenderer) RenderRequests(w io.Writer, meta ReportMetadata, logEntries []log.LogEntry) error {
+	ew := &errWriter{w: w}
+
+	fmt.Fprintf(ew, "Requests of %s in Log File: %s: %d\n\n", meta.User, meta.LogFile, len(logEntries))
+	if len(logEntries) == 0 {
+		return ew.err
+	}
+
+	rows := [][]string{{"Time", "IP", "Method", "URL", "Status", "Size", "UserAgent"}}
+	for _, entry := range logEntries {
+		rows = append(rows, []string{requestTime(entry), entry.IP, entry.Method, entry.URL, strconv.Itoa(entry.StatusCode), strconv.Itoa(entry.Size), entry.UserAgent})
+	}
+	truncateColumn(rows, 6, r.Width)
+
+	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgHiBlue).SprintfFunc()
+	tbl := table.New(rows[0][0], rows[0][1], rows[0][2], rows[0][3], rows[0][4], rows[0][5], rows[0][6]).WithWriter(ew)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range rows[1:] {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4], row[5], row[6])
+	}
+
+	tbl.Print()
+	fmt.Fprintln(ew)
+
+	return ew.err
+}
+
+// jsonRequestsReport is the JSON representation of a list of requests.
+type jsonRequestsReport struct {
+	LogFile  string        `json:"logFile"`
+	User     string        `json:"user,omitempty"`
+	Requests []jsonRequest `json:"requests"`
+}
+
+type jsonRequest struct {
+	Time       string `json:"time"`
+	IP         string `json:"ip"`
+	User       string `json:"user"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Protocol   string `json:"protocol"`
+	StatusCode int    `json:"statusCode"`
+	Size       int    `json:"size"`
+	Referrer   string `json:"referrer"`
+	UserAgent  string `json:"userAgent"`
+}
+
+// RenderRequests writes the requests as indented JSON.
+func (r *JSONRenderer) RenderRequests(w io.Writer, meta ReportMetadata, logEntries []log.LogEntry) error {
+	report := jsonRequestsReport{LogFile: meta.LogFile, User: meta.User, Requests: make([]jsonRequest, 0, len(logEntries))}
+	for _, entry := range logEntries {
+		report.Requests = append(report.Requests, jsonRequest{
+			Time:       requestTime(entry),
+			IP:         entry.IP,
+			User:       log.User(entry),
+			Method:     entry.Method,
+			URL:        entry.URL,
+			Protocol:   entry.Protocol,
+			StatusCode: entry.StatusCode,
+			Size:       entry.Size,
+			Referrer:   entry.Referrer,
+			UserAgent:  entry.UserAgent,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("error encoding requests: %w", err)
+	}
+
+	return nil
+}
+
+// CSVRenderer renders lists of requests as CSV, with a header row, for spreadsheets and other tools.
+type CSVRenderer struct{}
+
+// RenderRequests writes the requests as CSV, one row per request.
+func (r *CSVRenderer) RenderRequests(w io.Writer, meta ReportMetadata, logEntries []log.LogEntry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(requestFields); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	for _, entry := range logEntries {
+		if err := cw.Write(requestRecord(entry)); err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func Test_RequestsRenderer_RenderRequests(t *testing.T) {
+	disableColor(t)
+
+	zone := time.FixedZone("", 2*60*60)
+	logEntries := []log.LogEntry{
+		{
+			IP: "50.112.0.11", Identity: "-", UserID: "admin", Time: "11/Jul/2018:17:31:56 +0200",
+			Timestamp: time.Date(2018, 7, 11, 17, 31, 56, 0, zone), Method: "GET", URL: "/asset.js", Protocol: "HTTP/1.1",
+			StatusCode: 200, Size: 3574, Referrer: "-", UserAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/536.6 (KHTML, like Gecko) Chrome/20.0.1092.0 Safari/536.6",
+		},
+		{
+			IP: "50.112.0.11", Identity: "-", UserID: "admin", Time: "11/Jul/2018:17:61:05 +0200",
+			Method: "POST", URL: "/hosting/?plan=a,b", Protocol: "HTTP/1.1",
+			StatusCode: 403, Size: 0, Referrer: "http://example.net/", UserAgent: `curl/7.61.0 "quoted"`,
+		},
+	}
+
+	meta := ReportMetadata{LogFile: "access.log", User: "admin"}
+
+	tests := []struct {
+		name     string
+		renderer RequestsRenderer
+	}{
+		{name: "table", renderer: &TableRenderer{TableOptions: TableOptions{Width: 100}}},
+		{name: "json", renderer: &JSONRenderer{}},
+		{name: "csv", renderer: &CSVRenderer{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tt.renderer.RenderRequests(&buf, meta, logEntries)
+			assert.NoError(t, err)
+
+			assertGolden(t, "requests_"+tt.name, buf.Bytes())
+		})
+	}
+}
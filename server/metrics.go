@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// MaxURLLabels is the number of distinct URLs labelled in the metrics, further URLs are labelled "OTHER".
+const MaxURLLabels = 1000
+
+// Metrics exports Prometheus metrics derived from parsed log entries. Counters accumulate every log entry
+// observed, so they keep increasing as a tailed log grows, including after the log is rotated.
+type Metrics struct {
+	urlNormaliser *log.URLNormaliser
+	urls          map[string]bool // the URLs labelled so far
+	registry      *prometheus.Registry
+
+	requests      *prometheus.CounterVec
+	responseSizes *prometheus.HistogramVec
+	lines         prometheus.Counter
+	parseErrors   prometheus.Counter
+}
+
+// NewMetrics returns metrics with URL labels normalised by the URL normalisation config, and always with their
+// query dropped and IDs in their path templated. The label values, and so time series, are bounded: methods
+// other than the standard methods are labelled "OTHER", as are URLs beyond the first MaxURLLabels.
+func NewMetrics(urlConfig log.URLNormaliserConfig) (*Metrics, error) {
+	urlConfig.DropQuery, urlConfig.KeepQueryParams, urlConfig.TemplateIDs = true, nil, true
+	urlNormaliser, err := log.NewURLNormaliser(urlConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metrics{
+		urlNormaliser: urlNormaliser,
+		urls:          make(map[string]bool),
+		registry:      prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "digio_http_requests_total",
+			Help: "Number of logged HTTP requests, by method, status class and normalised URL.",
+		}, []string{"method", "status_class", "url"}),
+		responseSizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "digio_http_response_size_bytes",
+			Help:    "Size of logged HTTP response bodies, by method and status class.",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+		}, []string{"method", "status_class"}),
+		lines: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "digio_log_lines_total",
+			Help: "Number of log lines read.",
+		}),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "digio_log_parse_errors_total",
+			Help: "Number of log lines omitted because they could not be parsed.",
+		}),
+	}
+
+	m.registry.MustRegister(m.requests, m.responseSizes, m.lines, m.parseErrors)
+
+	return m, nil
+}
+
+// Observe adds parsed log entries, and the parse report of the lines they were parsed from, to the metrics.
+func (m *Metrics) Observe(logEntries []log.LogEntry, report *log.ParseReport) {
+	for _, entry := range logEntries {
+		method := entry.Method
+		if !log.StandardMethod(method) {
+			method = otherLabel
+		}
+
+		statusClass := statusClass(entry.StatusCode)
+		m.requests.WithLabelValues(method, statusClass, m.urlLabel(entry.URL)).Inc()
+		m.responseSizes.WithLabelValues(method, statusClass).Observe(float64(entry.Size))
+	}
+
+	if report != nil {
+		m.lines.Add(float64(report.TotalLines))
+		m.parseErrors.Add(float64(len(report.Errors)))
+	}
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// otherLabel is the label value of methods and URLs which are not labelled individually.
+const otherLabel = "OTHER"
+
+// urlLabel returns the label of a URL, its normalised form unless MaxURLLabels URLs are already labelled.
+func (m *Metrics) urlLabel(rawURL string) string {
+	url := m.urlNormaliser.Normalise(rawURL)
+	if !m.urls[url] {
+		if len(m.urls) >= MaxURLLabels {
+			return otherLabel
+		}
+		m.urls[url] = true
+	}
+
+	return url
+}
+
+// statusClass returns the class of a status code, e.g. 2xx.
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(statusCode/100) + "xx"
+}
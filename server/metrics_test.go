@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func Test_Metrics(t *testing.T) {
+	m, err := NewMetrics(log.URLNormaliserConfig{Patterns: []string{"/blog/**"}})
+	assert.NoError(t, err)
+
+	m.Observe([]log.LogEntry{
+		{Method: "GET", URL: "/users/1", StatusCode: 200, Size: 50},
+		{Method: "GET", URL: "/users/2?tab=posts", StatusCode: 200, Size: 5000},
+		{Method: "POST", URL: "/login", StatusCode: 401, Size: 0},
+		{Method: "GET", URL: "/blog/2018/08/", StatusCode: 200, Size: 20000},
+		{Method: "PROPFIND", URL: "/", StatusCode: 405, Size: 0},
+	}, &log.ParseReport{TotalLines: 4, Errors: []log.ParseIssue{{LineNumber: 2, Message: "invalid log entry"}}})
+
+	// metrics keep counting as a tailed log grows
+	m.Observe([]log.LogEntry{
+		{Method: "GET", URL: "/users/3", StatusCode: 503, Size: 200},
+	}, &log.ParseReport{TotalLines: 1})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+
+	for _, want := range []string{
+		`digio_http_requests_total{method="GET",status_class="2xx",url="/users/{id}"} 2`,
+		`digio_http_requests_total{method="GET",status_class="5xx",url="/users/{id}"} 1`,
+		`digio_http_requests_total{method="POST",status_class="4xx",url="/login"} 1`,
+		`digio_http_requests_total{method="GET",status_class="2xx",url="/blog/**"} 1`,
+		`digio_http_requests_total{method="OTHER",status_class="4xx",url="/"} 1`,
+		`digio_http_response_size_bytes_bucket{method="GET",status_class="2xx",le="100"} 1`,
+		`digio_http_response_size_bytes_bucket{method="GET",status_class="2xx",le="10000"} 2`,
+		`digio_http_response_size_bytes_sum{method="GET",status_class="2xx"} 25050`,
+		`digio_http_response_size_bytes_count{method="GET",status_class="2xx"} 3`,
+		"digio_log_lines_total 5",
+		"digio_log_parse_errors_total 1",
+	} {
+		assert.Contains(t, string(body), want)
+	}
+}
+
+func Test_Metrics_boundedLabels(t *testing.T) {
+	m, err := NewMetrics(log.URLNormaliserConfig{})
+	assert.NoError(t, err)
+
+	// distinct IDs and query strings are labelled together
+	var logEntries []log.LogEntry
+	for i := 0; i < 5000; i++ {
+		logEntries = append(logEntries, log.LogEntry{Method: "GET", URL: fmt.Sprintf("/users/%d?session=%d", i, i), StatusCode: 200})
+		logEntries = append(logEntries, log.LogEntry{Method: fmt.Sprintf("X-%d", i), URL: "/", StatusCode: 200})
+	}
+	// beyond MaxURLLabels, distinct URLs are labelled as other
+	for i := 0; i < 2*MaxURLLabels; i++ {
+		logEntries = append(logEntries, log.LogEntry{Method: "GET", URL: fmt.Sprintf("/page-%d", i), StatusCode: 404})
+	}
+	m.Observe(logEntries, nil)
+
+	assert.Equal(t, 5000.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "2xx", "/users/{id}")))
+	assert.Equal(t, 5000.0, testutil.ToFloat64(m.requests.WithLabelValues("OTHER", "2xx", "/")))
+	assert.Equal(t, float64(MaxURLLabels+2), testutil.ToFloat64(m.requests.WithLabelValues("GET", "4xx", "OTHER")))
+	assert.Equal(t, MaxURLLabels+1, testutil.CollectAndCount(m.requests))
+}
+
+func Test_statusClass(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       string
+	}{
+		{statusCode: 200, want: "2xx"},
+		{statusCode: 304, want: "3xx"},
+		{statusCode: 404, want: "4xx"},
+		{statusCode: 599, want: "5xx"},
+		{statusCode: 0, want: "unknown"},
+		{statusCode: 999, want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, statusClass(tt.statusCode))
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryannortham/digio-task/log"
+	"github.com/ryannortham/digio-task/render"
+)
+
+// Server serves the analysis of log entries as JSON over HTTP. Log entries can be added while the server is
+// running, e.g. as a log file is tailed, and each request analyses the log entries held at that time.
+type Server struct {
+	analyzer log.LogAnalyzer
+	logFile  string
+	topN     int
+	now      func() time.Time
+
+	mu         sync.RWMutex
+	logEntries []log.LogEntry
+}
+
+// NewServer returns a server which analyses log entries with the analyzer, reporting the top N values
+// unless a request asks for a different N.
+func NewServer(analyzer log.LogAnalyzer, logFile string, topN int) *Server {
+	return &Server{analyzer: analyzer, logFile: logFile, topN: topN, now: time.Now}
+}
+
+// SetLogEntries replaces the log entries held by the server.
+func (s *Server) SetLogEntries(logEntries []log.LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logEntries = logEntries
+}
+
+// AddLogEntries adds log entries to those held by the server.
+func (s *Server) AddLogEntries(logEntries []log.LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logEntries = append(s.logEntries, logEntries...)
+}
+
+// Handler returns the HTTP handler for the server's endpoints:
+//
+//	/analysis      the LogAnalysis of the log entries, as rendered by the JSONRenderer
+//	/top/urls      the most visited URLs
+//	/top/ips       the most active IPs
+//	/status-codes  the number of requests for each status code
+//
+// All endpoints accept since and until query parameters, limiting the analysis to a period. They are given
+// as RFC 3339 times, log times or durations before now, e.g. since=1h. The /analysis and /top endpoints
+// also accept n, the number of top values to report.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analysis", s.handleAnalysis)
+	mux.HandleFunc("/top/urls", s.handleTop(func(la *log.LogAnalysis) [][]string { return la.TopNMostVisitedURLs }))
+	mux.HandleFunc("/top/ips", s.handleTop(func(la *log.LogAnalysis) [][]string { return la.TopNMostActiveIPs }))
+	mux.HandleFunc("/status-codes", s.handleStatusCodes)
+
+	return mux
+}
+
+// period is the time range of log entries requested, either end is open when nil.
+type period struct {
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+type analysisResponse struct {
+	LogFile string `json:"logFile"`
+	TopN    int    `json:"topN"`
+	period
+	Analysis render.JSONAnalysis `json:"analysis"`
+}
+
+type topResponse struct {
+	LogFile string `json:"logFile"`
+	TopN    int    `json:"topN"`
+	period
+	Values []rankedValue `json:"values"`
+}
+
+type rankedValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type statusCodesResponse struct {
+	LogFile string `json:"logFile"`
+	period
+	StatusCodes map[int]int `json:"statusCodes"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	la, topN, p, ok := s.analyse(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analysisResponse{LogFile: s.logFile, TopN: topN, period: p, Analysis: render.NewJSONAnalysis(la)})
+}
+
+func (s *Server) handleTop(records func(*log.LogAnalysis) [][]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		la, topN, p, ok := s.analyse(w, r)
+		if !ok {
+			return
+		}
+
+		values := make([]rankedValue, 0, topN)
+		for _, row := range records(la)[1:] {
+			count, _ := log.ParseInt(row[1])
+			values = append(values, rankedValue{Value: row[0], Count: count})
+		}
+
+		writeJSON(w, http.StatusOK, topResponse{LogFile: s.logFile, TopN: topN, period: p, Values: values})
+	}
+}
+
+func (s *Server) handleStatusCodes(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	p, err := s.parsePeriod(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	statusCodes := make(map[int]int)
+	for _, entry := range s.periodLogEntries(p) {
+		statusCodes[entry.StatusCode]++
+	}
+
+	writeJSON(w, http.StatusOK, statusCodesResponse{LogFile: s.logFile, period: p, StatusCodes: statusCodes})
+}
+
+// analyse analyses the log entries in the requested period, writing an error response when the request is
+// invalid or there is nothing to analyse.
+func (s *Server) analyse(w http.ResponseWriter, r *http.Request) (*log.LogAnalysis, int, period, bool) {
+	if !allowGet(w, r) {
+		return nil, 0, period{}, false
+	}
+
+	topN := s.topN
+	if n := r.URL.Query().Get("n"); n != "" {
+		var err error
+		if topN, err = strconv.Atoi(n); err != nil || topN < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("n must be a positive integer"))
+			return nil, 0, period{}, false
+		}
+	}
+
+	p, err := s.parsePeriod(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, 0, period{}, false
+	}
+
+	logEntries := s.periodLogEntries(p)
+	if len(logEntries) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no log entries in the requested period"))
+		return nil, 0, period{}, false
+	}
+
+	la, err := s.analyzer.GetLogAnalysis(logEntries, topN)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("error analysing log entries: %w", err))
+		return nil, 0, period{}, false
+	}
+
+	return la, topN, p, true
+}
+
+// periodLogEntries returns the log entries in a period. Log entries without a valid timestamp are only
+// included when the period is open at both ends.
+func (s *Server) periodLogEntries(p period) []log.LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p.Since == nil && p.Until == nil {
+		return s.logEntries
+	}
+
+	logEntries := make([]log.LogEntry, 0)
+	for _, entry := range s.logEntries {
+		if entry.Timestamp.IsZero() ||
+			p.Since != nil && entry.Timestamp.Before(*p.Since) ||
+			p.Until != nil && !entry.Timestamp.Before(*p.Until) {
+			continue
+		}
+		logEntries = append(logEntries, entry)
+	}
+
+	return logEntries
+}
+
+func (s *Server) parsePeriod(r *http.Request) (period, error) {
+	var p period
+	var err error
+
+	if p.Since, err = s.parseTime(r.URL.Query().Get("since")); err != nil {
+		return period{}, fmt.Errorf("invalid since: %w", err)
+	}
+	if p.Until, err = s.parseTime(r.URL.Query().Get("until")); err != nil {
+		return period{}, fmt.Errorf("invalid until: %w", err)
+	}
+
+	return p, nil
+}
+
+// parseTime parses an RFC 3339 time, a log time, or a duration before now. An empty value is nil.
+func (s *Server) parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		t := s.now().Add(-d)
+		return &t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	if t, err := log.ParseLogTime(value); err == nil {
+		return &t, nil
+	}
+
+	return nil, fmt.Errorf("%q is not an RFC 3339 time, log time or duration", value)
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
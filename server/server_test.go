@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func newTestServer() *Server {
+	start := time.Date(2018, 7, 10, 10, 0, 0, 0, time.UTC)
+
+	s := NewServer(&log.CombinedLogAnalyzer{ClampTopN: true}, "access.log", 2)
+	s.now = func() time.Time { return start.Add(3 * time.Hour) }
+	s.SetLogEntries([]log.LogEntry{
+		{IP: "168.41.191.40", URL: "/faq/", StatusCode: 200, Timestamp: start},
+		{IP: "168.41.191.40", URL: "/faq/", StatusCode: 200, Timestamp: start.Add(30 * time.Minute)},
+		{IP: "168.41.191.41", URL: "/docs/", StatusCode: 404, Timestamp: start.Add(time.Hour)},
+		{IP: "177.71.128.21", URL: "/", StatusCode: 200, Timestamp: start.Add(2 * time.Hour)},
+		{IP: "177.71.128.21", URL: "/docs/", StatusCode: 200, Timestamp: start.Add(150 * time.Minute)},
+		{IP: "72.44.32.10", URL: "/", StatusCode: 500, Timestamp: start.Add(170 * time.Minute)},
+	})
+
+	return s
+}
+
+func Test_Server_analysis(t *testing.T) {
+	s := newTestServer()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/analysis", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var got analysisResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "access.log", got.LogFile)
+	assert.Equal(t, 2, got.TopN)
+	assert.Nil(t, got.Since)
+	assert.Equal(t, 6, got.Analysis.RequestCount)
+	assert.Equal(t, 4, got.Analysis.UniqueIPCount)
+	assert.Equal(t, 2, len(got.Analysis.TopURLs))
+	assert.Equal(t, "/", got.Analysis.TopURLs[0].Value)
+	assert.Equal(t, 2, got.Analysis.TopURLs[0].Count)
+}
+
+func Test_Server_top(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantTopN   int
+		wantValues []rankedValue
+		wantError  string
+	}{
+		{
+			name:       "top urls",
+			target:     "/top/urls",
+			wantStatus: http.StatusOK,
+			wantTopN:   2,
+			wantValues: []rankedValue{{Value: "/", Count: 2}, {Value: "/docs/", Count: 2}},
+		},
+		{
+			name:       "top urls with n",
+			target:     "/top/urls?n=3",
+			wantStatus: http.StatusOK,
+			wantTopN:   3,
+			wantValues: []rankedValue{{Value: "/", Count: 2}, {Value: "/docs/", Count: 2}, {Value: "/faq/", Count: 2}},
+		},
+		{
+			name:       "top ips since a duration ago",
+			target:     "/top/ips?n=1&since=1h30m",
+			wantStatus: http.StatusOK,
+			wantTopN:   1,
+			wantValues: []rankedValue{{Value: "177.71.128.21", Count: 2}},
+		},
+		{
+			name:       "top urls until an RFC 3339 time",
+			target:     "/top/urls?n=1&until=2018-07-10T11:00:00Z",
+			wantStatus: http.StatusOK,
+			wantTopN:   1,
+			wantValues: []rankedValue{{Value: "/faq/", Count: 2}},
+		},
+		{
+			name:       "top urls since a log time",
+			target:     "/top/urls?n=1&since=10/Jul/2018:12:00:00%20%2B0000",
+			wantStatus: http.StatusOK,
+			wantTopN:   1,
+			wantValues: []rankedValue{{Value: "/", Count: 2}},
+		},
+		{
+			name:       "invalid n",
+			target:     "/top/urls?n=0",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "n must be a positive integer",
+		},
+		{
+			name:       "invalid since",
+			target:     "/top/urls?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantError:  `invalid since: "yesterday" is not an RFC 3339 time, log time or duration`,
+		},
+		{
+			name:       "invalid until",
+			target:     "/top/urls?until=2018-07-10",
+			wantStatus: http.StatusBadRequest,
+			wantError:  `invalid until: "2018-07-10" is not an RFC 3339 time, log time or duration`,
+		},
+		{
+			name:       "no log entries in period",
+			target:     "/top/urls?since=2019-01-01T00:00:00Z",
+			wantStatus: http.StatusNotFound,
+			wantError:  "no log entries in the requested period",
+		},
+		{
+			name:       "n greater than the number of urls",
+			target:     "/top/urls?n=50",
+			wantStatus: http.StatusOK,
+			wantTopN:   50,
+			wantValues: []rankedValue{{Value: "/", Count: 2}, {Value: "/docs/", Count: 2}, {Value: "/faq/", Count: 2}},
+		},
+	}
+
+	s := newTestServer()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantError != "" {
+				var got errorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+				assert.Equal(t, tt.wantError, got.Error)
+				return
+			}
+
+			var got topResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantTopN, got.TopN)
+			assert.Equal(t, tt.wantValues, got.Values)
+		})
+	}
+}
+
+// failingAnalyzer is a log analyzer which always fails.
+type failingAnalyzer struct{}
+
+func (failingAnalyzer) GetLogAnalysis([]log.LogEntry, int) (*log.LogAnalysis, error) {
+	return nil, errors.New("analysis failed")
+}
+
+func Test_Server_analyzerError(t *testing.T) {
+	s := newTestServer()
+	s.analyzer = failingAnalyzer{}
+
+	for _, target := range []string{"/analysis", "/top/urls", "/top/ips"} {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+			var got errorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, "error analysing log entries: analysis failed", got.Error)
+		})
+	}
+}
+
+func Test_Server_statusCodes(t *testing.T) {
+	s := newTestServer()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status-codes", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got statusCodesResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[int]int{200: 4, 404: 1, 500: 1}, got.StatusCodes)
+
+	// log entries added after the server starts are included
+	s.AddLogEntries([]log.LogEntry{{IP: "72.44.32.10", URL: "/", StatusCode: 404, Timestamp: s.now()}})
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status-codes?since=1h", nil))
+
+	got = statusCodesResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[int]int{200: 2, 404: 1, 500: 1}, got.StatusCodes)
+}
+
+func Test_Server_methodNotAllowed(t *testing.T) {
+	s := newTestServer()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/analysis", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
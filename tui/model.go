@@ -0,0 +1,533 @@
+package tui
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+// Model is an interactive view of the analysis of log entries held in memory. The analyses can be switched
+// between, re-run with a different top N or filter, sorted, and the requests of an IP listed.
+type Model struct {
+	analyzer   log.LogAnalyzer
+	logEntries []log.LogEntry
+	logFile    string
+
+	topN     int
+	filter   string
+	filtered []log.LogEntry
+	analysis *log.LogAnalysis
+
+	tables  []resultTable
+	current int
+	// drill lists the requests of drillIP in place of the current table, when not nil
+	drill   *resultTable
+	drillIP string
+
+	cursor     int
+	sortColumn int // -1 keeps the order of the analysis
+	sortDesc   bool
+
+	editing     bool
+	input       string
+	savedFilter string
+
+	status string
+	height int
+}
+
+// resultTable is a table of results, such as the most visited URLs.
+type resultTable struct {
+	title  string
+	header []string
+	rows   [][]string
+	// drillable tables have IPs or networks in their first column, whose requests can be listed
+	drillable bool
+}
+
+// NewModel analyses the log entries matching the filter expression, returning an error when the initial
+// analysis fails.
+func NewModel(analyzer log.LogAnalyzer, logEntries []log.LogEntry, logFile string, topN int, filter string) (*Model, error) {
+	m := &Model{analyzer: analyzer, logEntries: logEntries, logFile: logFile, topN: topN, sortColumn: -1, height: 24}
+
+	if err := m.applyFilter(filter); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Run runs the interactive view until the user quits.
+func Run(m *Model) error {
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if m.editing {
+			m.updateFilterInput(msg)
+			return m, nil
+		}
+
+		return m, m.updateKey(msg)
+	}
+
+	return m, nil
+}
+
+func (m *Model) updateKey(msg tea.KeyMsg) tea.Cmd {
+	m.status = ""
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "tab", "right", "l":
+		m.switchTable(1)
+	case "shift+tab", "left", "h":
+		m.switchTable(-1)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.table().rows)-1, 0))
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "+", "=":
+		m.setTopN(m.topN + 1)
+	case "-":
+		m.setTopN(max(m.topN-1, 1))
+	case "s":
+		// cycle through the columns, and back to the order of the analysis
+		m.sortColumn++
+		if m.sortColumn == len(m.table().header) {
+			m.sortColumn = -1
+		}
+		m.sortDesc = false
+	case "r":
+		m.sortDesc = !m.sortDesc
+	case "enter":
+		m.drillDown()
+	case "esc":
+		if m.drill != nil {
+			m.drill, m.drillIP = nil, ""
+			m.resetTableState()
+		}
+	case "/":
+		m.editing, m.input, m.savedFilter = true, m.filter, m.filter
+	}
+
+	return nil
+}
+
+// updateFilterInput edits the filter expression, applying it as it is typed whenever it is valid.
+func (m *Model) updateFilterInput(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.editing = false
+		return
+	case tea.KeyEsc:
+		m.editing = false
+		m.status = ""
+		_ = m.applyFilter(m.savedFilter)
+		return
+	case tea.KeyBackspace:
+		if runes := []rune(m.input); len(runes) > 0 {
+			m.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		m.input += " "
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	default:
+		return
+	}
+
+	m.status = ""
+	if err := m.applyFilter(m.input); err != nil {
+		m.status = err.Error()
+	}
+}
+
+func (m *Model) switchTable(delta int) {
+	if m.drill != nil {
+		return
+	}
+
+	m.current = (m.current + delta + len(m.tables)) % len(m.tables)
+	m.resetTableState()
+}
+
+func (m *Model) resetTableState() {
+	m.cursor, m.sortColumn, m.sortDesc = 0, -1, false
+}
+
+func (m *Model) setTopN(topN int) {
+	previous := m.topN
+	m.topN = topN
+
+	if err := m.analyse(); err != nil {
+		m.topN = previous
+		m.status = fmt.Sprintf("top %d: %s", topN, err)
+	}
+}
+
+// applyFilter analyses the log entries matching a filter expression, leaving the analysis unchanged when
+// the filter is invalid or matches no log entries.
+func (m *Model) applyFilter(expression string) error {
+	filter, err := log.NewExpressionFilter(expression)
+	if err != nil {
+		return err
+	}
+
+	filtered := filter.FilterLogEntries(m.logEntries)
+	if len(filtered) == 0 {
+		return fmt.Errorf("no log entries match filter: %s", expression)
+	}
+
+	previousFilter, previousFiltered := m.filter, m.filtered
+	m.filter, m.filtered = expression, filtered
+
+	if err := m.analyse(); err != nil {
+		m.filter, m.filtered = previousFilter, previousFiltered
+		return err
+	}
+
+	return nil
+}
+
+// analyse re-runs the analysis of the filtered log entries, keeping the current table where it still exists.
+func (m *Model) analyse() error {
+	analysis, err := m.analyzer.GetLogAnalysis(m.filtered, m.topN)
+	if err != nil {
+		return err
+	}
+
+	var title string
+	if len(m.tables) > 0 {
+		title = m.tables[m.current].title
+	}
+
+	m.analysis = analysis
+	m.tables = newResultTables(analysis)
+
+	// find the current table again by its title, as the tables of an analysis can change, resetting the sort
+	// order of a table no longer shown
+	current := slices.IndexFunc(m.tables, func(t resultTable) bool { return t.title == title })
+	if current < 0 {
+		m.current = min(m.current, len(m.tables)-1)
+		if m.drill == nil {
+			m.resetTableState()
+		}
+	} else {
+		m.current = current
+	}
+
+	if m.drill != nil {
+		m.drill = newRequestsTable(m.drillIP, m.filtered)
+	}
+
+	m.cursor = min(m.cursor, max(len(m.table().rows)-1, 0))
+
+	return nil
+}
+
+// drillDown lists the requests of the IP or network under the cursor.
+func (m *Model) drillDown() {
+	t := m.table()
+	if m.drill != nil || !t.drillable || len(t.rows) == 0 {
+		return
+	}
+
+	m.drillIP = m.sortedRows()[m.cursor][0]
+	m.drill = newRequestsTable(m.drillIP, m.filtered)
+	m.resetTableState()
+}
+
+// table returns the table being viewed.
+func (m *Model) table() *resultTable {
+	if m.drill != nil {
+		return m.drill
+	}
+
+	return &m.tables[m.current]
+}
+
+// sortedRows returns the rows of the table being viewed, in the selected sort order.
+func (m *Model) sortedRows() [][]string {
+	t := m.table()
+	rows := make([][]string, len(t.rows))
+	copy(rows, t.rows)
+
+	if m.sortColumn < 0 {
+		if m.sortDesc {
+			for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+				rows[i], rows[j] = rows[j], rows[i]
+			}
+		}
+		return rows
+	}
+
+	col := m.sortColumn
+	numeric := true
+	for _, row := range rows {
+		if _, err := strconv.ParseFloat(row[col], 64); err != nil {
+			numeric = false
+			break
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i][col], rows[j][col]
+		if m.sortDesc {
+			a, b = b, a
+		}
+
+		if numeric {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return x < y
+		}
+		return a < b
+	})
+
+	return rows
+}
+
+func (m *Model) View() string {
+	var b strings.Builder
+	bold := color.New(color.Bold).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+
+	fmt.Fprintf(&b, "%s %s: %d of %d log entries, top %d\n",
+		bold("Log analysis of"), m.logFile, len(m.filtered), len(m.logEntries), m.topN)
+
+	// the tabs of the tables, or the IP drilled into
+	if m.drill != nil {
+		fmt.Fprintf(&b, "%s\n", bold(m.drill.title))
+	} else {
+		for i, t := range m.tables {
+			if i == m.current {
+				fmt.Fprintf(&b, "[%s] ", bold(t.title))
+			} else {
+				fmt.Fprintf(&b, " %s  ", t.title)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if m.editing {
+		fmt.Fprintf(&b, "Filter: %s█\n\n", m.input)
+	} else if m.filter != "" {
+		fmt.Fprintf(&b, "Filter: %s\n\n", m.filter)
+	} else {
+		fmt.Fprintf(&b, "Filter: %s\n\n", faint("none"))
+	}
+
+	m.writeTable(&b)
+
+	if m.status != "" {
+		fmt.Fprintf(&b, "\n%s\n", color.New(color.FgHiRed).Sprint(m.status))
+	}
+
+	help := "tab switch · ↑/↓ select · +/- top N · s sort · r reverse · enter requests · / filter · q quit"
+	switch {
+	case m.editing:
+		help = "enter apply · esc cancel"
+	case m.drill != nil:
+		help = "↑/↓ select · s sort · r reverse · / filter · esc back · q quit"
+	}
+	fmt.Fprintf(&b, "\n%s\n", faint(help))
+
+	return b.String()
+}
+
+// writeTable writes the rows of the table being viewed which fit on the screen, scrolled to the cursor.
+func (m *Model) writeTable(b *strings.Builder) {
+	t := m.table()
+	rows := m.sortedRows()
+
+	header := make([]any, 0, len(t.header)+1)
+	header = append(header, "")
+	for i, h := range t.header {
+		switch {
+		case i == m.sortColumn && m.sortDesc:
+			h += " ▼"
+		case i == m.sortColumn:
+			h += " ▲"
+		}
+		header = append(header, h)
+	}
+
+	tbl := table.New(header...).WithWriter(b)
+	tbl.WithHeaderFormatter(color.New(color.FgBlue, color.Underline).SprintfFunc())
+
+	// leave room for the header, filter, status and help lines
+	visible := max(m.height-10, 1)
+	start := max(m.cursor-visible+1, 0)
+
+	for i := start; i < min(start+visible, len(rows)); i++ {
+		marker := " "
+		if i == m.cursor {
+			marker = ">"
+		}
+
+		row := make([]any, 0, len(rows[i])+1)
+		row = append(row, marker)
+		for _, value := range rows[i] {
+			row = append(row, value)
+		}
+		tbl.AddRow(row...)
+	}
+
+	tbl.Print()
+}
+
+// newResultTables returns the tables of the analyses in a log analysis.
+func newResultTables(la *log.LogAnalysis) []resultTable {
+	tables := []resultTable{
+		newRecordsTable("URLs", la.TopNMostVisitedURLs, false),
+		newRecordsTable("IPs", la.TopNMostActiveIPs, true),
+	}
+
+	if la.TopNCountries != nil {
+		tables = append(tables, newRecordsTable("Countries", la.TopNCountries, false))
+	}
+	if la.TopNASNs != nil {
+		tables = append(tables, newRecordsTable("Networks", la.TopNASNs, false))
+	}
+
+	for _, values := range la.GroupedBy {
+		tables = append(tables, newRecordsTable(values.Field, values.TopNValues, false))
+	}
+
+	if s := la.Sessions; s != nil {
+		tables = append(tables,
+			newRecordsTable("Entry pages", s.TopNEntryPages, false),
+			newRecordsTable("Exit pages", s.TopNExitPages, false),
+			newRecordsTable("Transitions", s.TopNTransitions, false),
+		)
+	}
+
+	if m := la.Methods; m != nil {
+		tables = append(tables,
+			newRecordsTable("Methods", m.TopNMethods, false),
+			newRecordsTable("Protocols", m.TopNProtocols, false),
+		)
+	}
+
+	if u := la.Users; u != nil {
+		t := resultTable{title: "Users", header: []string{"User", "Requests", "URLs", "First seen", "Last seen"}}
+		for _, activity := range u.TopNUsers {
+			t.rows = append(t.rows, []string{activity.User, strconv.Itoa(activity.RequestCount), strconv.Itoa(activity.URLCount),
+				formatTime(activity.FirstSeen), formatTime(activity.LastSeen)})
+		}
+		tables = append(tables, t)
+	}
+
+	if r := la.Referrers; r != nil {
+		tables = append(tables,
+			newRecordsTable("Referrers", r.TopNDomains, false),
+			newRecordsTable("Search terms", r.TopNSearchTerms, false),
+		)
+	}
+
+	// the latency tables are shown even when no log entries have a response time, so that the tables don't
+	// change as a filter is applied
+	if l := la.Latency; l != nil {
+		tables = append(tables,
+			newLatencyTable("Latency", l.TopNURLs),
+			newLatencyTable("Slowest URLs", l.TopNSlowestURLs),
+		)
+	}
+
+	if la.Findings != nil {
+		t := resultTable{title: "Anomalies", header: []string{"Severity", "Kind", "Subject", "Detail", "Count"}}
+		for _, f := range la.Findings {
+			t.rows = append(t.rows, []string{f.Severity.String(), f.Kind, f.Subject, f.Detail, strconv.Itoa(f.Count)})
+		}
+		tables = append(tables, t)
+	}
+
+	return tables
+}
+
+// newLatencyTable returns a table of the response time percentiles of URLs, in milliseconds so they sort
+// numerically.
+func newLatencyTable(title string, urls []log.URLLatency) resultTable {
+	t := resultTable{title: title, header: []string{"URL", "Requests", "P50 ms", "P90 ms", "P99 ms"}}
+	for _, u := range urls {
+		t.rows = append(t.rows, []string{u.URL, strconv.Itoa(u.RequestCount), formatMillis(u.P50), formatMillis(u.P90), formatMillis(u.P99)})
+	}
+
+	return t
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64)
+}
+
+// newRecordsTable returns a table of the top N records of an analysis, with their counts as integers.
+func newRecordsTable(title string, records [][]string, drillable bool) resultTable {
+	t := resultTable{title: title, header: []string{records[0][0], "Count"}, drillable: drillable}
+	for _, record := range records[1:] {
+		count, _ := log.ParseInt(record[1])
+		t.rows = append(t.rows, []string{record[0], strconv.Itoa(count)})
+	}
+
+	return t
+}
+
+// newRequestsTable lists the requests of an IP, or of the IPs in a network such as 168.41.191.0/24.
+func newRequestsTable(ip string, logEntries []log.LogEntry) *resultTable {
+	match := func(entry log.LogEntry) bool { return entry.IP == ip }
+	if prefix, err := netip.ParsePrefix(ip); err == nil {
+		match = func(entry log.LogEntry) bool {
+			addr, err := netip.ParseAddr(entry.IP)
+			return err == nil && prefix.Contains(addr)
+		}
+	}
+
+	t := &resultTable{title: "Requests of " + ip, header: []string{"Time", "Method", "URL", "Status", "Size"}}
+	for _, entry := range logEntries {
+		if !match(entry) {
+			continue
+		}
+
+		// show times in UTC so they sort in time order
+		time := entry.Time
+		if !entry.Timestamp.IsZero() {
+			time = entry.Timestamp.Format(timeLayout)
+		}
+
+		t.rows = append(t.rows, []string{time, entry.Method, entry.URL, strconv.Itoa(entry.StatusCode), strconv.Itoa(entry.Size)})
+	}
+
+	return t
+}
+
+// timeLayout formats times in tables, so that they sort in time order.
+const timeLayout = "2006-01-02 15:04:05"
+
+// formatTime formats a time for a table, or "-" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(timeLayout)
+}
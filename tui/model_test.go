@@ -0,0 +1,235 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ryannortham/digio-task/log"
+)
+
+func newTestModel(t *testing.T, analyzer log.LogAnalyzer) *Model {
+	start := time.Date(2018, 7, 10, 10, 0, 0, 0, time.UTC)
+
+	m, err := NewModel(analyzer, []log.LogEntry{
+		{IP: "168.41.191.40", Method: "GET", URL: "/faq/", StatusCode: 200, Size: 100, Timestamp: start},
+		{IP: "168.41.191.40", Method: "GET", URL: "/docs/", StatusCode: 200, Size: 300, Timestamp: start.Add(time.Minute)},
+		{IP: "168.41.191.40", Method: "POST", URL: "/faq/", StatusCode: 404, Size: 0, Timestamp: start.Add(2 * time.Minute)},
+		{IP: "177.71.128.21", Method: "GET", URL: "/", StatusCode: 200, Size: 50, Timestamp: start.Add(3 * time.Minute)},
+		{IP: "177.71.128.21", Method: "GET", URL: "/faq/", StatusCode: 200, Size: 100, Timestamp: start.Add(4 * time.Minute)},
+		{IP: "72.44.32.10", Method: "GET", URL: "/docs/", StatusCode: 500, Size: 0, Timestamp: start.Add(5 * time.Minute)},
+	}, "access.log", 2, "")
+	assert.NoError(t, err)
+
+	return m
+}
+
+func sendKeys(m *Model, keys ...string) {
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "tab":
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+
+		m.Update(msg)
+	}
+}
+
+func typeText(m *Model, text string) {
+	for _, r := range text {
+		sendKeys(m, string(r))
+	}
+}
+
+func Test_Model_switchTables(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{Anomalies: &log.AnomalyDetector{}})
+
+	titles := make([]string, 0)
+	for range m.tables {
+		titles = append(titles, m.table().title)
+		sendKeys(m, "tab")
+	}
+
+	assert.Equal(t, []string{"URLs", "IPs", "Anomalies"}, titles)
+	assert.Equal(t, "URLs", m.table().title)
+	assert.Equal(t, []string{"/faq/", "3"}, m.table().rows[0])
+}
+
+func Test_Model_topN(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{})
+
+	sendKeys(m, "+")
+	assert.Equal(t, 3, m.topN)
+	assert.Equal(t, [][]string{{"/faq/", "3"}, {"/docs/", "2"}, {"/", "1"}}, m.table().rows)
+
+	// the analyzer cannot report more URLs than there are, so the top N is unchanged
+	sendKeys(m, "+")
+	assert.Equal(t, 3, m.topN)
+	assert.Contains(t, m.status, "top 4:")
+
+	sendKeys(m, "-", "-", "-")
+	assert.Equal(t, 1, m.topN)
+	assert.Equal(t, [][]string{{"/faq/", "3"}}, m.table().rows)
+}
+
+func Test_Model_sort(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{})
+	sendKeys(m, "+")
+
+	tests := []struct {
+		name string
+		keys []string
+		want [][]string
+	}{
+		{
+			name: "analysis order",
+			want: [][]string{{"/faq/", "3"}, {"/docs/", "2"}, {"/", "1"}},
+		},
+		{
+			name: "by url",
+			keys: []string{"s"},
+			want: [][]string{{"/", "1"}, {"/docs/", "2"}, {"/faq/", "3"}},
+		},
+		{
+			name: "by url reversed",
+			keys: []string{"r"},
+			want: [][]string{{"/faq/", "3"}, {"/docs/", "2"}, {"/", "1"}},
+		},
+		{
+			name: "by count",
+			keys: []string{"s"},
+			want: [][]string{{"/", "1"}, {"/docs/", "2"}, {"/faq/", "3"}},
+		},
+		{
+			name: "back to analysis order",
+			keys: []string{"s"},
+			want: [][]string{{"/faq/", "3"}, {"/docs/", "2"}, {"/", "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendKeys(m, tt.keys...)
+			assert.Equal(t, tt.want, m.sortedRows())
+		})
+	}
+}
+
+func Test_Model_drillDown(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{})
+
+	// requests are only listed for IPs
+	sendKeys(m, "enter")
+	assert.Nil(t, m.drill)
+
+	sendKeys(m, "tab", "down", "enter")
+	assert.Equal(t, "Requests of 177.71.128.21", m.table().title)
+	assert.Equal(t, [][]string{
+		{"2018-07-10 10:03:00", "GET", "/", "200", "50"},
+		{"2018-07-10 10:04:00", "GET", "/faq/", "200", "100"},
+	}, m.table().rows)
+
+	// tables cannot be switched while drilled into an IP
+	sendKeys(m, "tab")
+	assert.Equal(t, "Requests of 177.71.128.21", m.table().title)
+
+	sendKeys(m, "esc")
+	assert.Nil(t, m.drill)
+	assert.Equal(t, "IPs", m.table().title)
+}
+
+func Test_Model_drillDown_network(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{IPv4PrefixLen: 16})
+
+	sendKeys(m, "tab", "enter")
+	assert.Equal(t, "Requests of 168.41.0.0/16", m.table().title)
+	assert.Len(t, m.table().rows, 3)
+}
+
+func Test_Model_filter(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{})
+
+	sendKeys(m, "/")
+	typeText(m, "Method == GET and")
+	assert.True(t, m.editing)
+
+	// an incomplete filter is reported, and the last valid filter typed is kept
+	assert.Contains(t, m.status, "expected")
+	assert.Equal(t, "Method == GET ", m.filter)
+	assert.Len(t, m.filtered, 5)
+
+	typeText(m, " URL != /docs/")
+	assert.Equal(t, "", m.status)
+	assert.Equal(t, "Method == GET and URL != /docs/", m.filter)
+	assert.Len(t, m.filtered, 3)
+	assert.Equal(t, [][]string{{"/faq/", "2"}, {"/", "1"}}, m.table().rows)
+
+	sendKeys(m, "enter")
+	assert.False(t, m.editing)
+	assert.Equal(t, "Method == GET and URL != /docs/", m.filter)
+
+	// cancelling an edit restores the previous filter
+	sendKeys(m, "/", "backspace")
+	assert.Equal(t, "Method == GET and URL != /docs", m.filter)
+
+	sendKeys(m, "esc")
+	assert.False(t, m.editing)
+	assert.Equal(t, "Method == GET and URL != /docs/", m.filter)
+	assert.Len(t, m.filtered, 3)
+}
+
+func Test_Model_View(t *testing.T) {
+	m := newTestModel(t, &log.CombinedLogAnalyzer{})
+	sendKeys(m, "s", "r")
+
+	view := m.View()
+	assert.Contains(t, view, "access.log: 6 of 6 log entries, top 2")
+	assert.Contains(t, view, "Filter: none")
+	assert.Contains(t, view, "URL ▼")
+	assert.Contains(t, view, ">  /faq/")
+}
+
+func Test_Model_filter_latency(t *testing.T) {
+	responseTime := 120 * time.Millisecond
+	m, err := NewModel(&log.CombinedLogAnalyzer{Latency: &log.LatencyAnalyzer{Interval: time.Hour}}, []log.LogEntry{
+		{IP: "168.41.191.40", Method: "GET", URL: "/faq/", StatusCode: 200, ResponseTime: &responseTime},
+		{IP: "177.71.128.21", Method: "GET", URL: "/docs/", StatusCode: 404},
+	}, "access.log", 1, "")
+	assert.NoError(t, err)
+
+	for m.table().title != "Slowest URLs" {
+		sendKeys(m, "tab")
+	}
+	sendKeys(m, "s", "s", "s", "s", "s")
+	assert.Equal(t, 4, m.sortColumn)
+
+	// the latency tables are still shown when no log entries matching the filter have a response time
+	sendKeys(m, "/")
+	typeText(m, "StatusCode == 404")
+	sendKeys(m, "enter")
+	assert.Equal(t, "Slowest URLs", m.table().title)
+	assert.Empty(t, m.table().rows)
+	assert.NotPanics(t, func() { m.View() })
+
+	// the sort order is reset when the table being viewed is no longer shown
+	m.analyzer = &log.CombinedLogAnalyzer{}
+	sendKeys(m, "-")
+	assert.Equal(t, "IPs", m.table().title)
+	assert.Equal(t, -1, m.sortColumn)
+	assert.NotPanics(t, func() { m.View() })
+}